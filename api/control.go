@@ -0,0 +1,337 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"envisaMon/state"
+	"envisaMon/tpi"
+)
+
+// DefaultConfirmTimeout is how long a control action waits for the panel to
+// report the new partition state
+const DefaultConfirmTimeout = 30 * time.Second
+
+// Honeywell keypad command keys entered after the user code
+const (
+	keyDisarm  = "1"
+	keyAway    = "2"
+	keyStay    = "3"
+	keyMax     = "4"
+	keyBypass  = "6"
+	keyInstant = "7"
+	keyClear   = "*" // Abandons a partly entered sequence
+)
+
+// keyEntryTimeout is how long the panel keeps a partly entered sequence. A
+// variable to allow tuning in tests.
+var keyEntryTimeout = 10 * time.Second
+
+// armKeys maps an arm mode to its command key
+var armKeys = map[string]string{
+	state.ArmedAway:    keyAway,
+	state.ArmedStay:    keyStay,
+	state.ArmedInstant: keyInstant,
+	state.ArmedMaximum: keyMax,
+}
+
+// Keypad sends keystrokes to a partition, as tpi.Client.Keypress does
+type Keypad interface {
+	Keypress(partition int, keys string) error
+}
+
+// ControlOptions configures the control endpoints
+type ControlOptions struct {
+	Keypad          Keypad
+	Token           string        // Required bearer token
+	Code            string        // Default user code, used when a request supplies none
+	ThreeDigitZones bool          // The panel takes 3 digit zone numbers, as the VISTA-128 and VISTA-250 do
+	ConfirmTimeout  time.Duration // Defaults to DefaultConfirmTimeout
+	Logger          *log.Logger   // Audit log of actions; never given the user code
+}
+
+// control performs keypad actions. mu serialises them, since keys from two
+// sequences interleaved on the panel would enter neither.
+type control struct {
+	ControlOptions
+	mu         sync.Mutex
+	quietUntil time.Time // No keys are sent before this, see abandonEntry
+}
+
+// controlRequest is the optional body of a control request
+type controlRequest struct {
+	Code string `json:"code"`
+}
+
+// ControlResponse is the body of a successful control request
+type ControlResponse struct {
+	Action    string          `json:"action"`
+	Mode      string          `json:"mode,omitempty"`
+	Zone      int             `json:"zone,omitempty"`
+	Partition state.Partition `json:"partition"` // State that confirmed the action
+}
+
+// EnableControl adds the authenticated POST endpoints that arm, disarm and
+// bypass through the keypad
+func (s *Server) EnableControl(opts ControlOptions) error {
+	if opts.Keypad == nil {
+		return fmt.Errorf("control API needs a keypad")
+	}
+	if opts.Token == "" {
+		return fmt.Errorf("control API needs a bearer token")
+	}
+	if opts.Code != "" {
+		if err := validCode(opts.Code); err != nil {
+			return err
+		}
+	}
+	if opts.ConfirmTimeout <= 0 {
+		opts.ConfirmTimeout = DefaultConfirmTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(io.Discard, "", 0)
+	}
+
+	c := &control{ControlOptions: opts}
+	s.mux.HandleFunc("POST /partitions/{n}/arm", s.authorized(c, s.handleArm))
+	s.mux.HandleFunc("POST /partitions/{n}/disarm", s.authorized(c, s.handleDisarm))
+	s.mux.HandleFunc("POST /zones/{n}/bypass", s.authorized(c, s.handleBypass))
+	return nil
+}
+
+// ReadCodeFile reads a user code from a secret file, ignoring surrounding whitespace
+func ReadCodeFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	code := strings.TrimSpace(string(data))
+	if err := validCode(code); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return code, nil
+}
+
+// validCode checks a user code is 4 digits. The error never includes the code.
+func validCode(code string) error {
+	if len(code) != 4 {
+		return fmt.Errorf("user code must be 4 digits")
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return fmt.Errorf("user code must be 4 digits")
+		}
+	}
+	return nil
+}
+
+// controlHandler handles a request that passed authentication with its user code
+type controlHandler func(c *control, w http.ResponseWriter, r *http.Request, code string)
+
+// authorized checks the bearer token and resolves the user code before calling h
+func (s *Server) authorized(c *control, h controlHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="envisaMon"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+
+		var req controlRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+				writeError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+		}
+		code := req.Code
+		if code == "" {
+			code = c.Code
+		}
+		if code == "" {
+			writeError(w, http.StatusBadRequest, "no user code in the request and none configured")
+			return
+		}
+		if err := validCode(code); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h(c, w, r, code)
+	}
+}
+
+func (s *Server) handleArm(c *control, w http.ResponseWriter, r *http.Request, code string) {
+	n, ok := pathNumber(w, r, "partition", tpi.MaxPartitions)
+	if !ok {
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	key, ok := armKeys[mode]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("mode must be away, stay, instant or max, got: %q", mode))
+		return
+	}
+
+	p, ok := s.store.Partition(n)
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("partition %d has not been reported by the panel", n))
+		return
+	case p.ArmedMode != state.ArmedNone:
+		writeError(w, http.StatusConflict, fmt.Sprintf("partition %d is already armed %s", n, p.ArmedMode))
+		return
+	case !p.Ready && !p.Stale:
+		writeError(w, http.StatusConflict, fmt.Sprintf("partition %d is not ready to arm", n))
+		return
+	}
+
+	// The panel may report the exit delay before the armed state
+	confirmed := func(p state.Partition) bool {
+		return !p.Stale && (p.ArmedMode == mode || p.ExitDelay)
+	}
+	c.Logger.Printf("INFO: Control API: arming partition %d %s for %s", n, mode, r.RemoteAddr)
+	s.act(c, w, r, n, code+key, confirmed, ControlResponse{Action: "arm", Mode: mode})
+}
+
+func (s *Server) handleDisarm(c *control, w http.ResponseWriter, r *http.Request, code string) {
+	n, ok := pathNumber(w, r, "partition", tpi.MaxPartitions)
+	if !ok {
+		return
+	}
+	disarmed := func(p state.Partition) bool {
+		return p.ArmedMode == state.ArmedNone && !p.ExitDelay && !p.Alarm
+	}
+	p, ok := s.store.Partition(n)
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("partition %d has not been reported by the panel", n))
+		return
+	case disarmed(p) && !p.AlarmMemory:
+		// Nothing would change, so the panel could not confirm the code
+		writeError(w, http.StatusConflict, fmt.Sprintf("partition %d is already disarmed", n))
+		return
+	}
+
+	// Disarming again after an alarm clears the alarm memory
+	clearing := disarmed(p)
+	confirmed := func(p state.Partition) bool {
+		return !p.Stale && disarmed(p) && (!clearing || !p.AlarmMemory)
+	}
+	c.Logger.Printf("INFO: Control API: disarming partition %d for %s", n, r.RemoteAddr)
+	s.act(c, w, r, n, code+keyDisarm, confirmed, ControlResponse{Action: "disarm"})
+}
+
+// handleBypass bypasses a zone on ?partition= (default 1). Zones are entered as
+// two digits, or as three for every zone with ThreeDigitZones.
+func (s *Server) handleBypass(c *control, w http.ResponseWriter, r *http.Request, code string) {
+	maxZone, digits := 99, 2
+	if c.ThreeDigitZones {
+		maxZone, digits = tpi.ZonesEVL4, 3
+	}
+	zone, ok := pathNumber(w, r, "zone", maxZone)
+	if !ok {
+		return
+	}
+	n := 1
+	if v := r.URL.Query().Get("partition"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n < 1 || n > tpi.MaxPartitions {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("partition must be between 1 and %d, got: %q", tpi.MaxPartitions, v))
+			return
+		}
+	}
+
+	p, ok := s.store.Partition(n)
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("partition %d has not been reported by the panel", n))
+		return
+	case p.ArmedMode != state.ArmedNone:
+		writeError(w, http.StatusConflict, fmt.Sprintf("partition %d is armed %s, disarm it to bypass zones", n, p.ArmedMode))
+		return
+	case p.Bypass:
+		// The LED is shared by all zones, so it could not confirm this one
+		writeError(w, http.StatusConflict, fmt.Sprintf("partition %d already has zones bypassed, bypass zone %d at a keypad", n, zone))
+		return
+	}
+
+	confirmed := func(p state.Partition) bool {
+		return !p.Stale && p.Bypass
+	}
+	c.Logger.Printf("INFO: Control API: bypassing zone %d on partition %d for %s", zone, n, r.RemoteAddr)
+	s.act(c, w, r, n, fmt.Sprintf("%s%s%0*d", code, keyBypass, digits, zone), confirmed, ControlResponse{Action: "bypass", Zone: zone})
+}
+
+// abandonEntry clears a partly entered sequence so the next action's keys do
+// not continue it. If the clearing key cannot be sent either, no keys are sent
+// until the panel has discarded the entry itself. Called with c.mu held.
+func (c *control) abandonEntry(n int) {
+	if err := c.Keypad.Keypress(n, keyClear); err == nil {
+		return
+	}
+	c.Logger.Printf("WARN: Control API: could not clear the partial entry on partition %d, holding back keys for %v while the panel discards it", n, keyEntryTimeout)
+	c.quietUntil = time.Now().Add(keyEntryTimeout)
+}
+
+// act sends keys to partition n and waits for confirmed, writing the response.
+// keys contains the user code, so it is never logged or returned.
+func (s *Server) act(c *control, w http.ResponseWriter, r *http.Request, n int, keys string, confirmed func(state.Partition) bool, resp ControlResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if wait := time.Until(c.quietUntil); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("waiting %v for the panel to discard an incomplete key sequence", wait.Round(time.Second)))
+		return
+	}
+
+	if err := c.Keypad.Keypress(n, keys); err != nil {
+		c.Logger.Printf("ERROR: Control API: %s on partition %d failed: %v", resp.Action, n, err)
+		code := http.StatusBadGateway
+		var connErr *tpi.ConnectionError
+		if errors.As(err, &connErr) {
+			code = http.StatusServiceUnavailable
+		}
+		msg := fmt.Sprintf("sending keys to the panel failed: %v", err)
+		var keyErr *tpi.KeypressError
+		if errors.As(err, &keyErr) {
+			// Even an unacknowledged key may have reached the panel
+			c.abandonEntry(n)
+			msg = fmt.Sprintf("incomplete key sequence sent to the panel, %s was not entered: %v", resp.Action, err)
+		}
+		writeError(w, code, msg)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), c.ConfirmTimeout)
+	defer cancel()
+	p, err := s.store.WaitPartition(ctx, n, confirmed)
+	if err != nil && r.Context().Err() != nil {
+		c.Logger.Printf("WARN: Control API: %s on partition %d cancelled before the panel confirmed it", resp.Action, n)
+		writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("request cancelled before the panel confirmed %s, partition %d is %q", resp.Action, n, p.State))
+		return
+	}
+	if err != nil {
+		c.Logger.Printf("WARN: Control API: %s on partition %d not confirmed within %v", resp.Action, n, c.ConfirmTimeout)
+		writeError(w, http.StatusGatewayTimeout, fmt.Sprintf("panel did not confirm %s within %v, partition %d is %q", resp.Action, c.ConfirmTimeout, n, p.State))
+		return
+	}
+
+	c.Logger.Printf("INFO: Control API: %s on partition %d confirmed, state %q", resp.Action, n, p.State)
+	resp.Partition = p
+	writeJSON(w, http.StatusOK, resp)
+}
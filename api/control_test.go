@@ -0,0 +1,520 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"envisaMon/state"
+	"envisaMon/tpi"
+)
+
+const testToken = "s3cret-token"
+
+// fakeKeypad records keystrokes and plays panel responses into the store
+type fakeKeypad struct {
+	store    *state.Store
+	keys     []string
+	err      error
+	clearErr error    // Returned for the key that clears a partial entry
+	response []string // Lines the panel sends after the keys
+}
+
+func (k *fakeKeypad) Keypress(partition int, keys string) error {
+	k.keys = append(k.keys, keys)
+	if keys == keyClear {
+		return k.clearErr
+	}
+	if k.err != nil {
+		return k.err
+	}
+	for _, line := range k.response {
+		packet, err := tpi.Parse(line)
+		if err != nil {
+			return err
+		}
+		k.store.Apply(tpi.Message{Time: time.Now(), Raw: line, Packet: packet})
+	}
+	return nil
+}
+
+func TestServer_Control(t *testing.T) {
+	original := keyEntryTimeout
+	defer func() { keyEntryTimeout = original }()
+	keyEntryTimeout = time.Millisecond
+
+	const (
+		ready    = "%02,0100000000000000$"
+		notReady = "%02,0300000000000000$"
+		armed    = "%02,0500000000000000$"
+		exit     = "%02,0700000000000000$"
+		// Keypad with the bypass LED lit
+		bypassed = "%00,01,1C18,08,00,BYPAS 05 Front Door$"
+	)
+
+	tests := []struct {
+		name     string
+		initial  string // Partition 1 state before the request
+		target   string
+		token    string
+		body     string
+		code     string // Configured default code
+		vista128 bool   // Configured for 3 digit zones
+		keyErr   error
+		clearErr error
+		response []string
+		wantCode int
+		wantKeys string
+		wantBody string // Substring of the body
+		wantLog  string // Substring of the audit log
+	}{
+		{
+			name:     "arm away",
+			initial:  ready,
+			target:   "/partitions/1/arm?mode=away",
+			code:     "2580",
+			response: []string{exit},
+			wantCode: 200,
+			wantKeys: "25802",
+			wantBody: `{"action":"arm","mode":"away","partition":{"number":1,"state":"Exit Delay"`,
+		},
+		{
+			name:     "arm stay with code in request",
+			initial:  ready,
+			target:   "/partitions/1/arm?mode=stay",
+			body:     `{"code":"1397"}`,
+			code:     "2580",
+			response: []string{"%02,0400000000000000$"},
+			wantCode: 200,
+			wantKeys: "13973",
+		},
+		{
+			name:     "arm instant",
+			initial:  ready,
+			target:   "/partitions/1/arm?mode=instant",
+			code:     "2580",
+			response: []string{"%02,0600000000000000$"},
+			wantCode: 200,
+			wantKeys: "25807",
+		},
+		{
+			name:     "arm max",
+			initial:  ready,
+			target:   "/partitions/1/arm?mode=max",
+			code:     "2580",
+			response: []string{"%02,0A00000000000000$"},
+			wantCode: 200,
+			wantKeys: "25804",
+		},
+		{
+			name:     "disarm",
+			initial:  armed,
+			target:   "/partitions/1/disarm",
+			code:     "2580",
+			response: []string{ready},
+			wantCode: 200,
+			wantKeys: "25801",
+			wantBody: `"action":"disarm"`,
+		},
+		{
+			name:     "bypass",
+			initial:  notReady,
+			target:   "/zones/5/bypass",
+			code:     "2580",
+			response: []string{bypassed},
+			wantCode: 200,
+			wantKeys: "2580605",
+			wantBody: `"zone":5`,
+		},
+		{
+			name:     "bypass zone above 99",
+			initial:  notReady,
+			target:   "/zones/105/bypass?partition=1",
+			code:     "2580",
+			vista128: true,
+			response: []string{bypassed},
+			wantCode: 200,
+			wantKeys: "25806105",
+		},
+		{
+			name:     "bypass with three digit zones",
+			initial:  notReady,
+			target:   "/zones/5/bypass",
+			code:     "2580",
+			vista128: true,
+			response: []string{bypassed},
+			wantCode: 200,
+			wantKeys: "25806005",
+		},
+		{
+			name:     "bypass zone above 99 with two digit zones",
+			initial:  notReady,
+			target:   "/zones/105/bypass",
+			code:     "2580",
+			wantCode: 400,
+			wantBody: "between 1 and 99",
+		},
+		{
+			name:     "not confirmed",
+			initial:  ready,
+			target:   "/partitions/1/arm?mode=away",
+			code:     "2580",
+			wantCode: 504,
+			wantKeys: "25802",
+			wantBody: `partition 1 is \"Ready\"`,
+		},
+		{
+			name:     "not connected",
+			initial:  ready,
+			target:   "/partitions/1/arm?mode=away",
+			code:     "2580",
+			keyErr:   &tpi.ConnectionError{Message: "not connected"},
+			wantCode: 503,
+			wantKeys: "25802",
+		},
+		{
+			name:     "keypress rejected",
+			initial:  armed,
+			target:   "/partitions/1/disarm",
+			code:     "2580",
+			keyErr:   &tpi.CommandError{Command: tpi.CmdKeypress, Code: tpi.ResponseSyntaxError},
+			wantCode: 502,
+			wantKeys: "25801",
+		},
+		{
+			name:     "partial keypress cleared",
+			initial:  armed,
+			target:   "/partitions/1/disarm",
+			code:     "2580",
+			keyErr:   &tpi.KeypressError{Partition: 1, Sent: 2, Total: 5, Err: &tpi.CommandError{Command: tpi.CmdKeypress, Code: tpi.ResponseSyntaxError}},
+			wantCode: 502,
+			wantKeys: "25801|*",
+			wantBody: "incomplete key sequence",
+		},
+		{
+			name:     "first key not acknowledged",
+			initial:  armed,
+			target:   "/partitions/1/disarm",
+			code:     "2580",
+			keyErr:   &tpi.KeypressError{Partition: 1, Sent: 0, Total: 5, Err: &tpi.TimeoutError{Operation: "command ack"}},
+			wantCode: 502,
+			wantKeys: "25801|*",
+			wantBody: "incomplete key sequence",
+		},
+		{
+			name:     "partial keypress not cleared",
+			initial:  armed,
+			target:   "/partitions/1/disarm",
+			code:     "2580",
+			keyErr:   &tpi.KeypressError{Partition: 1, Sent: 3, Total: 5, Err: &tpi.ConnectionError{Message: "connection lost"}},
+			clearErr: &tpi.ConnectionError{Message: "not connected"},
+			wantCode: 503,
+			wantKeys: "25801|*",
+			wantBody: "incomplete key sequence",
+			wantLog:  "holding back keys for 1ms",
+		},
+		{
+			name:     "missing token",
+			initial:  ready,
+			target:   "/partitions/1/arm?mode=away",
+			token:    "-",
+			code:     "2580",
+			wantCode: 401,
+		},
+		{
+			name:     "wrong token",
+			initial:  ready,
+			target:   "/partitions/1/arm?mode=away",
+			token:    "guess",
+			code:     "2580",
+			wantCode: 401,
+		},
+		{
+			name:     "no code",
+			initial:  ready,
+			target:   "/partitions/1/disarm",
+			wantCode: 400,
+			wantBody: "no user code",
+		},
+		{
+			name:     "invalid code",
+			initial:  ready,
+			target:   "/partitions/1/disarm",
+			body:     `{"code":"12a4"}`,
+			wantCode: 400,
+			wantBody: "user code must be 4 digits",
+		},
+		{
+			name:     "invalid mode",
+			initial:  ready,
+			target:   "/partitions/1/arm?mode=night",
+			code:     "2580",
+			wantCode: 400,
+		},
+		{
+			name:     "not ready",
+			initial:  notReady,
+			target:   "/partitions/1/arm?mode=away",
+			code:     "2580",
+			wantCode: 409,
+			wantBody: "not ready to arm",
+		},
+		{
+			name:     "already armed",
+			initial:  armed,
+			target:   "/partitions/1/arm?mode=stay",
+			code:     "2580",
+			wantCode: 409,
+		},
+		{
+			name:     "disarm exit delay",
+			initial:  exit,
+			target:   "/partitions/1/disarm",
+			code:     "2580",
+			response: []string{ready},
+			wantCode: 200,
+			wantKeys: "25801",
+		},
+		{
+			name:     "disarm clears alarm memory",
+			initial:  "%02,0900000000000000$",
+			target:   "/partitions/1/disarm",
+			code:     "2580",
+			response: []string{"%00,01,1C08,08,00,READY$"},
+			wantCode: 200,
+			wantKeys: "25801",
+		},
+		{
+			name:     "alarm memory not cleared",
+			initial:  "%02,0900000000000000$",
+			target:   "/partitions/1/disarm",
+			code:     "2580",
+			wantCode: 504,
+			wantKeys: "25801",
+		},
+		{
+			name:     "already disarmed",
+			initial:  ready,
+			target:   "/partitions/1/disarm",
+			code:     "2580",
+			wantCode: 409,
+			wantBody: "already disarmed",
+		},
+		{
+			name:     "bypass while armed",
+			initial:  armed,
+			target:   "/zones/5/bypass",
+			code:     "2580",
+			wantCode: 409,
+		},
+		{
+			name:     "bypass with another zone bypassed",
+			initial:  bypassed,
+			target:   "/zones/7/bypass",
+			code:     "2580",
+			response: []string{bypassed},
+			wantCode: 409,
+			wantBody: "already has zones bypassed",
+		},
+		{
+			name:     "unknown partition",
+			initial:  ready,
+			target:   "/partitions/2/disarm",
+			code:     "2580",
+			wantCode: 404,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tpi.Status{}, tt.initial)
+			keypad := &fakeKeypad{store: srv.store, err: tt.keyErr, clearErr: tt.clearErr, response: tt.response}
+			var logs bytes.Buffer
+			err := srv.EnableControl(ControlOptions{
+				Keypad:          keypad,
+				Token:           testToken,
+				Code:            tt.code,
+				ThreeDigitZones: tt.vista128,
+				ConfirmTimeout:  50 * time.Millisecond,
+				Logger:          log.New(&logs, "", 0),
+			})
+			if err != nil {
+				t.Fatalf("EnableControl() error = %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			switch tt.token {
+			case "":
+				req.Header.Set("Authorization", "Bearer "+testToken)
+			case "-":
+			default:
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if got := strings.Join(keypad.keys, "|"); got != tt.wantKeys {
+				t.Errorf("keys sent = %q, want %q", got, tt.wantKeys)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body.String(), tt.wantBody)
+			}
+			if !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("log = %q, want it to contain %q", logs.String(), tt.wantLog)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+			for _, code := range []string{"2580", "1397"} {
+				if strings.Contains(logs.String(), code) || strings.Contains(rec.Body.String(), code) {
+					t.Errorf("user code %s leaked, log: %q, body: %q", code, logs.String(), rec.Body.String())
+				}
+			}
+			if rec.Code == http.StatusOK {
+				var resp ControlResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Errorf("invalid body %s: %v", rec.Body.String(), err)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_ControlHoldsBackKeysAfterUnclearedEntry(t *testing.T) {
+	original := keyEntryTimeout
+	defer func() { keyEntryTimeout = original }()
+	keyEntryTimeout = time.Minute
+
+	srv := newTestServer(t, tpi.Status{}, "%02,0500000000000000$")
+	keypad := &fakeKeypad{
+		store:    srv.store,
+		err:      &tpi.KeypressError{Partition: 1, Sent: 2, Total: 5, Err: &tpi.ConnectionError{Message: "connection lost"}},
+		clearErr: &tpi.ConnectionError{Message: "not connected"},
+	}
+	if err := srv.EnableControl(ControlOptions{Keypad: keypad, Token: testToken, Code: "2580"}); err != nil {
+		t.Fatalf("EnableControl() error = %v", err)
+	}
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/partitions/1/disarm", nil)
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		srv.ServeHTTP(rec, req)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("request took %v, want no waiting", elapsed)
+		}
+		return rec
+	}
+
+	post()
+	rec := post()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("second request = %d, Retry-After %q, want 503 after 60", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if got := strings.Join(keypad.keys, "|"); got != "25801|*" {
+		t.Errorf("keys sent = %q, want none after the failed clear", got)
+	}
+}
+
+func TestServer_ControlCancelled(t *testing.T) {
+	srv := newTestServer(t, tpi.Status{}, "%02,0100000000000000$")
+	keypad := &fakeKeypad{store: srv.store}
+	if err := srv.EnableControl(ControlOptions{Keypad: keypad, Token: testToken, Code: "2580"}); err != nil {
+		t.Fatalf("EnableControl() error = %v", err)
+	}
+
+	// As when the server shuts down while waiting for the panel
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/partitions/1/arm?mode=away", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	time.AfterFunc(10*time.Millisecond, cancel)
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "cancelled") {
+		t.Errorf("status code = %d, body %s, want 503 cancelled", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServer_EnableControl(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ControlOptions
+		wantErr string
+	}{
+		{name: "no keypad", opts: ControlOptions{Token: testToken}, wantErr: "needs a keypad"},
+		{name: "no token", opts: ControlOptions{Keypad: &fakeKeypad{}}, wantErr: "needs a bearer token"},
+		{name: "invalid code", opts: ControlOptions{Keypad: &fakeKeypad{}, Token: testToken, Code: "25805"}, wantErr: "4 digits"},
+		{name: "valid", opts: ControlOptions{Keypad: &fakeKeypad{}, Token: testToken, Code: "2580"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(state.New(), nil).EnableControl(tt.opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("EnableControl() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("EnableControl() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServer_ControlDisabled(t *testing.T) {
+	srv := newTestServer(t, tpi.Status{}, "%02,0100000000000000$")
+	req := httptest.NewRequest(http.MethodPost, "/partitions/1/disarm", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed && rec.Code != http.StatusNotFound {
+		t.Errorf("status code = %d, want control endpoints absent", rec.Code)
+	}
+}
+
+func TestReadCodeFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{name: "with newline", content: "2580\n", want: "2580"},
+		{name: "too long", content: "258056", wantErr: true},
+		{name: "empty", content: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := dir + "/" + strings.ReplaceAll(tt.name, " ", "-")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadCodeFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadCodeFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadCodeFile() = %q, want %q", got, tt.want)
+			}
+			if err != nil && strings.Contains(err.Error(), tt.content) && tt.content != "" {
+				t.Errorf("ReadCodeFile() error %q contains the file content", err)
+			}
+		})
+	}
+}
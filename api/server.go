@@ -0,0 +1,148 @@
+// Package api serves the alarm system state as JSON over HTTP
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"envisaMon/state"
+	"envisaMon/tpi"
+)
+
+// Server is an http.Handler exposing the state store and TPI connection status.
+// The GET endpoints are unauthenticated, so only listen on a trusted interface.
+// EnableControl adds authenticated endpoints that operate the panel.
+type Server struct {
+	store  *state.Store
+	status func() tpi.Status
+	mux    *http.ServeMux
+}
+
+// StatusResponse is the body of GET /status
+type StatusResponse struct {
+	Address            string         `json:"address"`
+	Connected          bool           `json:"connected"`
+	AuthenticatedSince *time.Time     `json:"authenticated_since"` // null while disconnected
+	LastMessage        *time.Time     `json:"last_message"`        // null if nothing has been received
+	Reconnects         int            `json:"reconnects"`
+	StateUpdated       *time.Time     `json:"state_updated"` // null if the state is unknown
+	Troubles           state.Troubles `json:"troubles"`
+	KeypadText         string         `json:"keypad_text"`
+}
+
+// errorResponse is the body of every non-2xx response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New returns a Server reading from store, with status typically tpi.Client.Status
+func New(store *state.Store, status func() tpi.Status) *Server {
+	s := &Server{store: store, status: status, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /status", s.handleStatus)
+	s.mux.HandleFunc("GET /partitions", s.handlePartitions)
+	s.mux.HandleFunc("GET /partitions/{n}", s.handlePartition)
+	s.mux.HandleFunc("GET /zones", s.handleZones)
+	s.mux.HandleFunc("GET /zones/{n}", s.handleZone)
+	s.mux.HandleFunc("GET /events", s.handleEvents)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	conn := s.status()
+	snap := s.store.Snapshot()
+	writeJSON(w, http.StatusOK, StatusResponse{
+		Address:            conn.Address,
+		Connected:          conn.Connected,
+		AuthenticatedSince: timeOrNil(conn.AuthenticatedSince),
+		LastMessage:        timeOrNil(conn.LastMessage),
+		Reconnects:         conn.Reconnects,
+		StateUpdated:       timeOrNil(snap.Updated),
+		Troubles:           snap.Troubles,
+		KeypadText:         snap.KeypadText,
+	})
+}
+
+func (s *Server) handlePartitions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.store.Snapshot().Partitions)
+}
+
+func (s *Server) handlePartition(w http.ResponseWriter, r *http.Request) {
+	n, ok := pathNumber(w, r, "partition", tpi.MaxPartitions)
+	if !ok {
+		return
+	}
+	p, ok := s.store.Partition(n)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("partition %d has not been reported by the panel", n))
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) handleZones(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.store.Snapshot().Zones)
+}
+
+func (s *Server) handleZone(w http.ResponseWriter, r *http.Request) {
+	n, ok := pathNumber(w, r, "zone", tpi.ZonesEVL4)
+	if !ok {
+		return
+	}
+	// A zone never seen open is reported closed
+	z, _ := s.store.Zone(n)
+	writeJSON(w, http.StatusOK, z)
+}
+
+// handleEvents returns the event history after ?since=, either a sequence
+// number or an RFC 3339 time
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		writeJSON(w, http.StatusOK, s.store.Events(0))
+		return
+	}
+	if seq, err := strconv.ParseUint(since, 10, 64); err == nil {
+		writeJSON(w, http.StatusOK, s.store.Events(seq))
+		return
+	}
+	if t, err := time.Parse(time.RFC3339Nano, since); err == nil {
+		writeJSON(w, http.StatusOK, s.store.EventsSince(t))
+		return
+	}
+	writeError(w, http.StatusBadRequest, fmt.Sprintf("since must be a sequence number or RFC 3339 time, got: %q", since))
+}
+
+// pathNumber parses the {n} path value, writing a 400 if it is not in 1..max
+func pathNumber(w http.ResponseWriter, r *http.Request, what string, max int) (int, bool) {
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil || n < 1 || n > max {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be between 1 and %d, got: %q", what, max, r.PathValue("n")))
+		return 0, false
+	}
+	return n, true
+}
+
+// timeOrNil maps the zero time to JSON null
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, errorResponse{Error: message})
+}
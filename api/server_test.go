@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"envisaMon/state"
+	"envisaMon/tpi"
+)
+
+var t0 = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestServer returns a Server over a store that has seen lines, one second apart
+func newTestServer(t *testing.T, status tpi.Status, lines ...string) *Server {
+	t.Helper()
+	store := state.New()
+	for i, line := range lines {
+		packet, err := tpi.Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", line, err)
+		}
+		store.Apply(tpi.Message{Time: t0.Add(time.Duration(i) * time.Second), Raw: line, Packet: packet})
+	}
+	return New(store, func() tpi.Status { return status })
+}
+
+func get(t *testing.T, h http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestServer_Status(t *testing.T) {
+	tests := []struct {
+		name   string
+		status tpi.Status
+		lines  []string
+		want   string
+	}{
+		{
+			name:   "never connected",
+			status: tpi.Status{Address: "192.168.1.50:4025"},
+			want:   `{"address":"192.168.1.50:4025","connected":false,"authenticated_since":null,"last_message":null,"reconnects":0,"state_updated":null,"troubles":{"ac_loss":false,"low_battery":false,"system_check":false,"fire":false,"stale":false},"keypad_text":""}`,
+		},
+		{
+			name: "connected",
+			status: tpi.Status{
+				Address:            "192.168.1.50:4025",
+				Connected:          true,
+				AuthenticatedSince: t0,
+				LastMessage:        t0.Add(time.Minute),
+				Reconnects:         2,
+			},
+			lines: []string{"%00,01,1C08,08,00,READY$"},
+			want:  `{"address":"192.168.1.50:4025","connected":true,"authenticated_since":"2024-03-01T12:00:00Z","last_message":"2024-03-01T12:01:00Z","reconnects":2,"state_updated":"2024-03-01T12:00:00Z","troubles":{"ac_loss":false,"low_battery":false,"system_check":false,"fire":false,"stale":false},"keypad_text":"READY"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(t, newTestServer(t, tt.status, tt.lines...), "/status")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status code = %d, want 200", rec.Code)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("body = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_Routes(t *testing.T) {
+	srv := newTestServer(t, tpi.Status{},
+		"%02,0500000000000000$", // Partition 1 armed away
+		"%01,0400000000000000$", // Zone 3 faulted
+	)
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		wantCode int
+		wantBody string // Substring of the body
+	}{
+		{name: "partitions", target: "/partitions", wantCode: 200, wantBody: `[{"number":1,"state":"Armed Away","armed_mode":"away"`},
+		{name: "partition", target: "/partitions/1", wantCode: 200, wantBody: `{"number":1,"state":"Armed Away"`},
+		{name: "partition not seen", target: "/partitions/2", wantCode: 404, wantBody: `{"error":"partition 2 has not been reported`},
+		{name: "partition out of range", target: "/partitions/9", wantCode: 400, wantBody: `{"error":"partition must be between 1 and 8`},
+		{name: "partition not a number", target: "/partitions/one", wantCode: 400, wantBody: `"error"`},
+		{name: "zones", target: "/zones", wantCode: 200, wantBody: `[{"number":3,"open":true`},
+		{name: "zone", target: "/zones/3", wantCode: 200, wantBody: `{"number":3,"open":true`},
+		{name: "zone never open", target: "/zones/4", wantCode: 200, wantBody: `{"number":4,"open":false`},
+		{name: "zone out of range", target: "/zones/0", wantCode: 400, wantBody: `{"error":"zone must be between 1 and 128`},
+		{name: "wrong method", method: http.MethodPost, target: "/zones", wantCode: 405},
+		{name: "unknown path", target: "/nope", wantCode: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(method, tt.target, nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServer_Events(t *testing.T) {
+	srv := newTestServer(t, tpi.Status{},
+		"%02,0100000000000000$", // 1: partition 1 ready, t0
+		"%01,0400000000000000$", // 2: zone 3 faulted, t0+1s
+		"%01,0000000000000000$", // 3: zone 3 restored, t0+2s
+	)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantSeqs []uint64
+	}{
+		{name: "all", query: "", wantCode: 200, wantSeqs: []uint64{1, 2, 3}},
+		{name: "since sequence", query: "?since=1", wantCode: 200, wantSeqs: []uint64{2, 3}},
+		{name: "since last sequence", query: "?since=3", wantCode: 200, wantSeqs: []uint64{}},
+		{name: "since time", query: "?since=2024-03-01T12:00:01Z", wantCode: 200, wantSeqs: []uint64{3}},
+		{name: "invalid since", query: "?since=yesterday", wantCode: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(t, srv, "/events"+tt.query)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var events []state.Event
+			if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+				t.Fatalf("invalid body %s: %v", rec.Body.String(), err)
+			}
+			if events == nil {
+				t.Fatalf("body = %s, want a JSON array", rec.Body.String())
+			}
+			if len(events) != len(tt.wantSeqs) {
+				t.Fatalf("got %d events, want %d: %s", len(events), len(tt.wantSeqs), rec.Body.String())
+			}
+			for i, seq := range tt.wantSeqs {
+				if events[i].Sequence != seq {
+					t.Errorf("event %d sequence = %d, want %d", i, events[i].Sequence, seq)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Batch formats for ReporterOptions.BatchFormat
+const (
+	BatchNone   = ""       // One Event object per request
+	BatchNDJSON = "ndjson" // Newline-delimited Event objects
+	BatchArray  = "array"  // A JSON array of Events
+)
+
+// Batching defaults
+const (
+	DefaultBatchSize     = 100
+	DefaultBatchInterval = 5 * time.Second
+)
+
+// encodeEvents serialises events for a single request in the configured
+// format, gzip-compressing the result if enabled
+func (ar *AsyncReporter) encodeEvents(events []Event) (body []byte, contentType string, err error) {
+	switch ar.batchFormat {
+	case BatchNone:
+		body, err = json.Marshal(events[0])
+		contentType = "application/json"
+	case BatchArray:
+		body, err = json.Marshal(events)
+		contentType = "application/json"
+	case BatchNDJSON:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, event := range events {
+			if err = enc.Encode(event); err != nil {
+				break
+			}
+		}
+		body = buf.Bytes()
+		contentType = "application/x-ndjson"
+	default:
+		err = fmt.Errorf("unknown batch format %q", ar.batchFormat)
+	}
+	if err != nil || !ar.gzip {
+		return body, contentType, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+// batcher groups queued messages into batches, flushing when a batch is full
+// or the interval elapses, and hands them to the workers
+func (ar *AsyncReporter) batcher() {
+	defer ar.workers.Done()
+	defer close(ar.batchChan)
+
+	ticker := time.NewTicker(ar.batchInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	flush := func() {
+		if len(batch) > 0 {
+			ar.batchChan <- batch
+			batch = nil
+		}
+	}
+
+	for {
+		select {
+		case rm, ok := <-ar.msgChan:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, ar.newEvent(rm))
+			if len(batch) >= ar.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// batchWorker delivers batches built by batcher
+func (ar *AsyncReporter) batchWorker() {
+	defer ar.workers.Done()
+	for batch := range ar.batchChan {
+		if ar.ctx.Err() != nil {
+			// Close gave up waiting, discard what is left
+			ar.lost.Add(int64(len(batch)))
+			continue
+		}
+		if err := ar.deliver(batch, ar.maxAttempts); err != nil && ar.ctx.Err() != nil {
+			ar.lost.Add(int64(len(batch)))
+		}
+	}
+}
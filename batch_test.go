@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// batchRequest is what the test server saw in one POST
+type batchRequest struct {
+	contentType string
+	gzipped     bool
+	events      []Event
+}
+
+// newBatchServer decodes each request according to its headers
+func newBatchServer(t *testing.T, requests chan<- batchRequest) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := batchRequest{
+			contentType: r.Header.Get("Content-Type"),
+			gzipped:     r.Header.Get("Content-Encoding") == "gzip",
+		}
+		body, _ := io.ReadAll(r.Body)
+		if req.gzipped {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Errorf("gzip body: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body, _ = io.ReadAll(zr)
+		}
+
+		switch req.contentType {
+		case "application/x-ndjson":
+			scanner := bufio.NewScanner(bytes.NewReader(body))
+			for scanner.Scan() {
+				var event Event
+				if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+					t.Errorf("NDJSON line %q: %v", scanner.Text(), err)
+				}
+				req.events = append(req.events, event)
+			}
+		default:
+			if err := json.Unmarshal(body, &req.events); err != nil {
+				t.Errorf("JSON array body %q: %v", body, err)
+			}
+		}
+		requests <- req
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func eventMessages(events []Event) string {
+	var msgs []string
+	for _, event := range events {
+		msgs = append(msgs, event.EventMessage)
+	}
+	return strings.Join(msgs, ",")
+}
+
+func TestAsyncReporter_Batching(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	tests := []struct {
+		name            string
+		opts            ReporterOptions
+		spool           bool
+		writes          []string
+		wantContentType string
+		wantBatches     []string // Event messages per request, in order
+	}{
+		{
+			name:            "ndjson flushes on size",
+			opts:            ReporterOptions{BatchFormat: BatchNDJSON, BatchSize: 2, BatchInterval: time.Hour},
+			writes:          []string{"a", "b"},
+			wantContentType: "application/x-ndjson",
+			wantBatches:     []string{"a,b"},
+		},
+		{
+			name:            "array flushes on interval with gzip",
+			opts:            ReporterOptions{BatchFormat: BatchArray, BatchSize: 100, BatchInterval: 20 * time.Millisecond, Gzip: true},
+			writes:          []string{"a", "b", "c"},
+			wantContentType: "application/json",
+			wantBatches:     []string{"a,b,c"},
+		},
+		{
+			name:            "spool batches in order",
+			opts:            ReporterOptions{BatchFormat: BatchNDJSON, BatchSize: 2, BatchInterval: 20 * time.Millisecond},
+			spool:           true,
+			writes:          []string{"a", "b", "c"},
+			wantContentType: "application/x-ndjson",
+			wantBatches:     []string{"a,b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := make(chan batchRequest, 10)
+			ts := newBatchServer(t, requests)
+			defer ts.Close()
+
+			opts := tt.opts
+			opts.CAFile = trustServer(t, ts).CAFile
+			if tt.spool {
+				opts.SpoolPath = filepath.Join(t.TempDir(), "spool.jsonl")
+			}
+
+			reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, opts)
+			if err != nil {
+				t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+			}
+			defer reporter.Close(context.Background())
+			for _, msg := range tt.writes {
+				reporter.Write([]byte(msg))
+			}
+
+			for i, want := range tt.wantBatches {
+				select {
+				case req := <-requests:
+					if got := eventMessages(req.events); got != want {
+						t.Errorf("batch %d = %q, want %q", i, got, want)
+					}
+					if req.contentType != tt.wantContentType {
+						t.Errorf("Content-Type = %q, want %q", req.contentType, tt.wantContentType)
+					}
+					if req.gzipped != tt.opts.Gzip {
+						t.Errorf("gzipped = %v, want %v", req.gzipped, tt.opts.Gzip)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("Timed out waiting for batch %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestAsyncReporter_CloseFlushesPartialBatch(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	requests := make(chan batchRequest, 10)
+	ts := newBatchServer(t, requests)
+	defer ts.Close()
+
+	opts := trustServer(t, ts)
+	opts.BatchFormat, opts.BatchSize, opts.BatchInterval = BatchArray, 100, time.Hour
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, opts)
+	if err != nil {
+		t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+	}
+	reporter.Write([]byte("zone 1 faulted"))
+	reporter.Write([]byte("zone 1 restored"))
+
+	if lost, err := reporter.Close(context.Background()); err != nil || lost != 0 {
+		t.Fatalf("Close() = %d, %v, want 0, nil", lost, err)
+	}
+	select {
+	case req := <-requests:
+		if got := eventMessages(req.events); got != "zone 1 faulted,zone 1 restored" {
+			t.Errorf("batch = %q", got)
+		}
+	default:
+		t.Fatal("Close() returned without flushing the partial batch")
+	}
+}
+
+func TestNewAsyncReporter_UnknownBatchFormat(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	_, err := NewAsyncReporterWithOptions("https://example.com", "test-system", "TPI", false, io.Discard, ReporterOptions{BatchFormat: "xml"})
+	if err == nil || !strings.Contains(err.Error(), "unknown batch format") {
+		t.Errorf("NewAsyncReporterWithOptions() error = %v, want unknown batch format", err)
+	}
+}
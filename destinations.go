@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultDestination names the destination given on the command line. Its
+// spool and dead-letter files keep their original names.
+const defaultDestination = "default"
+
+// Message types a destination can subscribe to
+var messageTypes = []string{"TPI", "Application"}
+
+// packetTypes are the values accepted in Destination.PacketTypes
+var packetTypes = []string{
+	PacketKeypadUpdate, PacketZoneStateChange, PacketPartitionStateChange,
+	PacketRealtimeCID, PacketZoneTimerDump, PacketCommandAck, PacketUnknown,
+}
+
+var destinationNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Destination is one remote reporting endpoint. Each gets its own reporters,
+// and so its own queue, spool and dead-letter files.
+type Destination struct {
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	APIKeyEnv     string   `json:"api_key_env"`     // Environment variable holding the API key (default ALARM_MON_API_KEY)
+	SigningKeyEnv string   `json:"signing_key_env"` // Environment variable holding the HMAC signing key, if any
+	MessageTypes  []string `json:"message_types"`   // "TPI" and/or "Application" (default both)
+	PacketTypes   []string `json:"packet_types"`    // Only report TPI messages of these packet types (default all)
+	Schema        int      `json:"schema"`
+	Spool         bool     `json:"spool"`
+	Batch         string   `json:"batch"`
+	BatchSize     int      `json:"batch_size"`
+	BatchInterval duration `json:"batch_interval"`
+	Gzip          bool     `json:"gzip"`
+	CAFile        string   `json:"ca_file"`
+	Pins          []string `json:"pins"`
+	Insecure      bool     `json:"insecure"`
+	CertFile      string   `json:"cert"`
+	KeyFile       string   `json:"key"`
+}
+
+// duration is a time.Duration written as a string such as "5s" in JSON
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// destinationsFile is the layout of the -destinations file
+type destinationsFile struct {
+	Destinations []Destination `json:"destinations"`
+}
+
+// loadDestinations reads and validates a destinations file
+func loadDestinations(path string) ([]Destination, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destinations file: %w", err)
+	}
+
+	var file destinationsFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid destinations file %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i := range file.Destinations {
+		d := &file.Destinations[i]
+		if err := d.validate(); err != nil {
+			return nil, fmt.Errorf("destination %d (%q): %w", i+1, d.Name, err)
+		}
+		if seen[d.Name] {
+			return nil, fmt.Errorf("duplicate destination name %q", d.Name)
+		}
+		seen[d.Name] = true
+	}
+	return file.Destinations, nil
+}
+
+// validate checks a destination from the file and fills in defaults
+func (d *Destination) validate() error {
+	if !destinationNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("name must be letters, digits, '-' or '_'")
+	}
+	if d.Name == defaultDestination {
+		return fmt.Errorf("name %q is reserved for the command line URL", defaultDestination)
+	}
+	if !strings.HasPrefix(d.URL, "https://") {
+		return fmt.Errorf("url must be https, got %q", d.URL)
+	}
+	if d.APIKeyEnv == "" {
+		d.APIKeyEnv = "ALARM_MON_API_KEY"
+	}
+	if len(d.MessageTypes) == 0 {
+		d.MessageTypes = messageTypes
+	}
+	for _, mt := range d.MessageTypes {
+		if !slices.Contains(messageTypes, mt) {
+			return fmt.Errorf("unknown message type %q", mt)
+		}
+	}
+	for _, pt := range d.PacketTypes {
+		if !slices.Contains(packetTypes, pt) {
+			return fmt.Errorf("unknown packet type %q", pt)
+		}
+	}
+	if d.Schema == 0 {
+		d.Schema = SchemaV1
+	}
+	if err := validSchema(d.Schema); err != nil {
+		return err
+	}
+	if d.BatchSize == 0 {
+		d.BatchSize = DefaultBatchSize
+	}
+	if d.BatchInterval == 0 {
+		d.BatchInterval = duration(DefaultBatchInterval)
+	}
+	if (d.CertFile == "") != (d.KeyFile == "") {
+		return fmt.Errorf("cert and key must be given together")
+	}
+	return nil
+}
+
+// commandLineDestination describes the destination given by the URL argument and flags
+func commandLineDestination(config *Config) Destination {
+	return Destination{
+		Name:          defaultDestination,
+		URL:           config.DestinationURL,
+		APIKeyEnv:     "ALARM_MON_API_KEY",
+		SigningKeyEnv: "ALARM_MON_SIGNING_KEY",
+		MessageTypes:  messageTypes,
+		Schema:        config.Schema,
+		Spool:         config.Spool,
+		Batch:         config.BatchFormat,
+		BatchSize:     config.BatchSize,
+		BatchInterval: duration(config.BatchInterval),
+		Gzip:          config.Gzip,
+		CAFile:        config.CAFile,
+		Pins:          config.PinnedSPKI,
+		Insecure:      config.InsecureTLS,
+		CertFile:      config.ClientCertFile,
+		KeyFile:       config.ClientKeyFile,
+	}
+}
+
+// logPath names a per-destination file under ./logs, e.g. spool-tpi.jsonl
+// for the default destination or spool-datalake-tpi.jsonl for "datalake"
+func (d Destination) logPath(kind, messageType string) string {
+	name := strings.ToLower(messageType)
+	if d.Name != defaultDestination {
+		name = d.Name + "-" + name
+	}
+	return fmt.Sprintf("./logs/%s-%s.jsonl", kind, name)
+}
+
+// reporterOptions returns the AsyncReporter settings for one message type
+func (d Destination) reporterOptions(messageType string) ReporterOptions {
+	opts := ReporterOptions{
+		APIKeyEnv:          d.APIKeyEnv,
+		DeadLetterPath:     d.logPath("dead-letter", messageType),
+		CAFile:             d.CAFile,
+		PinnedSPKI:         d.Pins,
+		InsecureSkipVerify: d.Insecure,
+		ClientCertFile:     d.CertFile,
+		ClientKeyFile:      d.KeyFile,
+		BatchFormat:        d.Batch,
+		BatchSize:          d.BatchSize,
+		BatchInterval:      time.Duration(d.BatchInterval),
+		Gzip:               d.Gzip,
+		Schema:             d.Schema,
+	}
+	if d.Spool {
+		opts.SpoolPath = d.logPath("spool", messageType)
+	}
+	if d.SigningKeyEnv != "" {
+		if key := os.Getenv(d.SigningKeyEnv); key != "" {
+			opts.SigningKey = []byte(key)
+		}
+	}
+	// Packet filters only make sense for TPI lines
+	if messageType == "TPI" {
+		opts.PacketTypes = d.PacketTypes
+	}
+	return opts
+}
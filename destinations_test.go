@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadDestinations(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []Destination
+		wantErr string
+	}{
+		{
+			name: "defaults filled in",
+			file: `{"destinations": [{"name": "datalake", "url": "https://lake.example.com/ingest"}]}`,
+			want: []Destination{{
+				Name:          "datalake",
+				URL:           "https://lake.example.com/ingest",
+				APIKeyEnv:     "ALARM_MON_API_KEY",
+				MessageTypes:  []string{"TPI", "Application"},
+				Schema:        SchemaV1,
+				BatchSize:     DefaultBatchSize,
+				BatchInterval: duration(DefaultBatchInterval),
+			}},
+		},
+		{
+			name: "filtered destination",
+			file: `{"destinations": [{"name": "central-station", "url": "https://cs.example.com", "api_key_env": "CS_KEY",
+				"message_types": ["TPI"], "packet_types": ["realtime_cid"], "schema": 2, "batch": "array", "batch_interval": "30s"}]}`,
+			want: []Destination{{
+				Name:          "central-station",
+				URL:           "https://cs.example.com",
+				APIKeyEnv:     "CS_KEY",
+				MessageTypes:  []string{"TPI"},
+				PacketTypes:   []string{PacketRealtimeCID},
+				Schema:        SchemaV2,
+				Batch:         BatchArray,
+				BatchSize:     DefaultBatchSize,
+				BatchInterval: duration(30 * time.Second),
+			}},
+		},
+		{
+			name:    "reserved name",
+			file:    `{"destinations": [{"name": "default", "url": "https://a.example.com"}]}`,
+			wantErr: "reserved",
+		},
+		{
+			name:    "duplicate name",
+			file:    `{"destinations": [{"name": "a", "url": "https://a.example.com"}, {"name": "a", "url": "https://b.example.com"}]}`,
+			wantErr: "duplicate destination name",
+		},
+		{
+			name:    "invalid name",
+			file:    `{"destinations": [{"name": "../etc", "url": "https://a.example.com"}]}`,
+			wantErr: "name must be",
+		},
+		{
+			name:    "http url",
+			file:    `{"destinations": [{"name": "a", "url": "http://a.example.com"}]}`,
+			wantErr: "url must be https",
+		},
+		{
+			name:    "unknown message type",
+			file:    `{"destinations": [{"name": "a", "url": "https://a.example.com", "message_types": ["LOG"]}]}`,
+			wantErr: "unknown message type",
+		},
+		{
+			name:    "unknown packet type",
+			file:    `{"destinations": [{"name": "a", "url": "https://a.example.com", "packet_types": ["cid"]}]}`,
+			wantErr: "unknown packet type",
+		},
+		{
+			name:    "unknown field",
+			file:    `{"destinations": [{"name": "a", "url": "https://a.example.com", "api_key": "secret"}]}`,
+			wantErr: "unknown field",
+		},
+		{
+			name:    "invalid duration",
+			file:    `{"destinations": [{"name": "a", "url": "https://a.example.com", "batch_interval": 5}]}`,
+			wantErr: "duration must be a string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "destinations.json")
+			os.WriteFile(path, []byte(tt.file), 0644)
+
+			got, err := loadDestinations(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadDestinations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadDestinations() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadDestinations() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestination_LogPath(t *testing.T) {
+	tests := []struct {
+		name, kind, messageType, want string
+	}{
+		{defaultDestination, "spool", "TPI", "./logs/spool-tpi.jsonl"},
+		{defaultDestination, "dead-letter", "Application", "./logs/dead-letter-application.jsonl"},
+		{"datalake", "spool", "TPI", "./logs/spool-datalake-tpi.jsonl"},
+	}
+
+	for _, tt := range tests {
+		if got := (Destination{Name: tt.name}).logPath(tt.kind, tt.messageType); got != tt.want {
+			t.Errorf("logPath(%s, %s, %s) = %q, want %q", tt.name, tt.kind, tt.messageType, got, tt.want)
+		}
+	}
+}
+
+func TestSetupLogging_MultipleDestinations(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "lake-key")
+	os.Setenv("TEST_CS_KEY", "cs-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+	defer os.Unsetenv("TEST_CS_KEY")
+
+	// Record what each destination receives, keyed by API key
+	var mu sync.Mutex
+	received := map[string][]string{}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &event)
+		mu.Lock()
+		received[r.Header.Get("X-API-Key")] = append(received[r.Header.Get("X-API-Key")], event.MessageType+" "+event.EventMessage)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tmpDir := t.TempDir()
+	caFile := trustServer(t, ts).CAFile
+	destinations := filepath.Join(tmpDir, "destinations.json")
+	os.WriteFile(destinations, []byte(`{"destinations": [
+		{"name": "datalake", "url": "`+ts.URL+`", "ca_file": "`+caFile+`"},
+		{"name": "central-station", "url": "`+ts.URL+`", "ca_file": "`+caFile+`", "api_key_env": "TEST_CS_KEY",
+		 "message_types": ["TPI"], "packet_types": ["realtime_cid"]}
+	]}`), 0644)
+
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	config := &Config{EnvisaLinkIP: "127.0.0.1", EnvisaLinkPort: 4025, DestinationsFile: destinations}
+	tpiLogger, appLogger, shutdown, err := setupLogging(config)
+	if err != nil {
+		t.Fatalf("setupLogging() error = %v", err)
+	}
+	tpiLogger.Println("%00,01,1C08,08,00,****DISARMED****  Ready to Arm  $")
+	tpiLogger.Println("%03,1131010050$")
+	appLogger.Println("INFO: test app message")
+	shutdown(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := received["cs-key"]; !reflect.DeepEqual(got, []string{"TPI %03,1131010050$"}) {
+		t.Errorf("central-station received %q, want only the CID event", got)
+	}
+	if got := received["lake-key"]; len(got) != 3 {
+		t.Errorf("datalake received %q, want all 3 messages", got)
+	}
+}
+
+func TestSetupLogging_DestinationWithoutCredentials(t *testing.T) {
+	os.Unsetenv("TEST_MISSING_KEY")
+
+	tmpDir := t.TempDir()
+	destinations := filepath.Join(tmpDir, "destinations.json")
+	os.WriteFile(destinations, []byte(`{"destinations": [{"name": "a", "url": "https://a.example.com", "api_key_env": "TEST_MISSING_KEY"}]}`), 0644)
+
+	oldWd, _ := os.Getwd()
+	os.Chdir(tmpDir)
+	defer os.Chdir(oldWd)
+
+	_, _, _, err := setupLogging(&Config{EnvisaLinkIP: "127.0.0.1", EnvisaLinkPort: 4025, DestinationsFile: destinations})
+	if err == nil || !strings.Contains(err.Error(), "$TEST_MISSING_KEY") {
+		t.Errorf("setupLogging() error = %v, want missing credentials", err)
+	}
+}
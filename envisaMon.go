@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
+	"envisaMon/api"
+	"envisaMon/state"
 	"envisaMon/tpi"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"gopkg.in/natefinch/lumberjack.v2"
 )
@@ -36,7 +44,7 @@ func main() {
 	}
 
 	// 3. Set up dual logging with lumberjack
-	tpiLogger, appLogger, err := setupLogging(config)
+	tpiLogger, appLogger, shutdownLogging, err := setupLogging(config)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: Failed to set up logging: %v\n", err)
 		os.Exit(1)
@@ -51,45 +59,95 @@ func main() {
 		appLogger,
 		config.DeduplicateLimit,
 	)
+	client.SetKeepaliveInterval(config.KeepaliveInterval)
+	client.SetIdleTimeout(config.IdleTimeout)
 
 	// 5. Set up signal handling for graceful shutdown
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigCh
-		appLogger.Println("INFO: Shutting down...")
-		client.Close()
-		os.Exit(0)
-	}()
-
-	// 6. Main monitoring loop with auto-reconnect
-	appLogger.Printf("INFO: Starting TPI monitor for %s", config.EnvisaLinkIP)
-	for {
-		err := client.Connect()
-		if err != nil {
-			// Connection or auth failed, will retry with backoff
-			continue
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Track the alarm system state from received messages, starting from the
+	// last snapshot until the panel confirms it
+	store := state.New()
+	if err := store.LoadSnapshot(stateSnapshotPath); err == nil {
+		appLogger.Printf("INFO: Restored state snapshot from %s, stale until confirmed by the panel", stateSnapshotPath)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		appLogger.Printf("WARN: Ignoring state snapshot: %v", err)
+	}
+	store.PersistTo(stateSnapshotPath, appLogger)
+	client.Handle(func(msg tpi.Message) { store.Apply(msg) })
+
+	shutdownAPI := func(context.Context) {}
+	if config.HTTPAddr != "" {
+		handler, err := newAPIHandler(config, store, client, appLogger)
+		if err == nil {
+			shutdownAPI, err = startStatusAPI(config.HTTPAddr, handler, appLogger)
 		}
-
-		// Connection established and authenticated
-		// ReadLoop() runs until error or disconnect
-		err = client.ReadLoop()
 		if err != nil {
-			appLogger.Printf("WARN: Connection lost: %v", err)
-			// Will reconnect with exponential backoff
+			appLogger.Printf("ERROR: %v", err)
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
 		}
 	}
+
+	// 6. Main monitoring loop with auto-reconnect, runs until a signal arrives
+	appLogger.Printf("INFO: Starting TPI monitor for %s", config.EnvisaLinkIP)
+	client.Run(ctx)
+
+	appLogger.Println("INFO: Shutting down...")
+
+	// Stop the API first, cancelling control requests still waiting for the
+	// panel, while the TPI connection is still open
+	apiCtx, cancelAPI := context.WithTimeout(context.Background(), apiShutdownTimeout)
+	shutdownAPI(apiCtx)
+	cancelAPI()
+	client.Close()
+	store.Close()
+
+	// 7. Give queued reports a chance to be delivered before exiting
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	shutdownLogging(shutdownCtx)
 }
 
 type Config struct {
-	EnvisaLinkIP     string
-	EnvisaLinkPort   int
-	DestinationURL   string
-	DestinationPath  string
-	Verbose          bool
-	Deduplicate      bool
-	DeduplicateLimit int
+	EnvisaLinkIP      string
+	EnvisaLinkPort    int
+	DestinationURL    string
+	DestinationPath   string
+	Verbose           bool
+	Deduplicate       bool
+	DeduplicateLimit  int
+	KeepaliveInterval time.Duration
+	IdleTimeout       time.Duration
+	Spool             bool
+	CAFile            string
+	PinnedSPKI        []string
+	InsecureTLS       bool
+	ClientCertFile    string
+	ClientKeyFile     string
+	BatchFormat       string
+	BatchSize         int
+	BatchInterval     time.Duration
+	Gzip              bool
+	Schema            int
+	DestinationsFile  string
+	HTTPAddr          string
+	Control           bool
+	CodeFile          string
+	ThreeDigitZones   bool
+}
+
+// stringList is a flag.Value collecting a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
 
 func parseArgs(args []string) (*Config, error) {
@@ -106,6 +164,7 @@ func parseArgs(args []string) (*Config, error) {
 		fmt.Fprintf(out, "  %s -v 192.168.1.100\n", os.Args[0])
 		fmt.Fprintf(out, "  %s -u 192.168.1.100\n", os.Args[0])
 		fmt.Fprintf(out, "  %s -u 100 192.168.1.100\n", os.Args[0])
+		fmt.Fprintf(out, "  %s -k 2m 192.168.1.100\n", os.Args[0])
 		fmt.Fprintf(out, "  %s 192.168.1.100 https://events.example.com:8080\n", os.Args[0])
 	}
 	return parseConfig(fs, args)
@@ -115,6 +174,24 @@ func parseConfig(fs *flag.FlagSet, args []string) (*Config, error) {
 	config := &Config{}
 	fs.BoolVar(&config.Verbose, "v", false, "verbose output (print logs and TPI messages to stdout)")
 	fs.BoolVar(&config.Deduplicate, "u", false, "deduplicate consecutive identical TPI messages. Optionally specify number of duplicates to ignore (e.g., -u 10)")
+	fs.DurationVar(&config.KeepaliveInterval, "k", tpi.DefaultKeepaliveInterval, "interval between keepalive POLL commands that reset the EnvisaLink network watchdog (0 disables)")
+	fs.BoolVar(&config.Spool, "s", false, "spool reported events to disk under ./logs and replay them in order until the API accepts them")
+	fs.DurationVar(&config.IdleTimeout, "w", tpi.DefaultIdleTimeout, "reconnect if no TPI data is received for this long (0 disables)")
+	fs.StringVar(&config.CAFile, "ca", "", "PEM `file` of CA certificates used to verify the event destination instead of the system roots")
+	fs.Var((*stringList)(&config.PinnedSPKI), "pin", "base64 SHA-256 `hash` of an accepted destination public key (repeatable)")
+	fs.BoolVar(&config.InsecureTLS, "insecure", false, "disable TLS certificate verification for the event destination (not recommended)")
+	fs.StringVar(&config.ClientCertFile, "cert", "", "PEM client certificate `file` for mutual TLS with the event destination (reloaded on change)")
+	fs.StringVar(&config.ClientKeyFile, "key", "", "PEM private key `file` for the -cert client certificate")
+	fs.StringVar(&config.BatchFormat, "batch", "", "send events in batches as `format` \"ndjson\" or \"array\" instead of one per request")
+	fs.IntVar(&config.BatchSize, "batch-size", DefaultBatchSize, "maximum events per batch")
+	fs.DurationVar(&config.BatchInterval, "batch-interval", DefaultBatchInterval, "send a partial batch after this long")
+	fs.BoolVar(&config.Gzip, "gzip", false, "gzip-compress requests to the event destination")
+	fs.StringVar(&config.DestinationsFile, "destinations", "", "JSON `file` listing additional reporting destinations, each with its own URL, credentials and filters")
+	fs.IntVar(&config.Schema, "schema", SchemaV1, "event payload schema `version`: 1 (message text only) or 2 (adds decoded TPI fields)")
+	fs.StringVar(&config.HTTPAddr, "http", "", "serve the read-only status API on `address`, e.g. 127.0.0.1:8080 (unauthenticated, disabled by default)")
+	fs.BoolVar(&config.Control, "control", false, "also serve the arm, disarm and bypass endpoints on the -http address, authenticated with the ALARM_MON_CONTROL_TOKEN bearer token")
+	fs.StringVar(&config.CodeFile, "code-file", "", "secret `file` holding the user code for -control requests that do not supply one")
+	fs.BoolVar(&config.ThreeDigitZones, "3digit-zones", false, "enter zone numbers as 3 digits when bypassing, as VISTA-128 and VISTA-250 panels require")
 
 	if err := fs.Parse(args); err != nil {
 		return nil, err
@@ -135,6 +212,63 @@ func parseConfig(fs *flag.FlagSet, args []string) (*Config, error) {
 		}
 	}
 
+	if config.KeepaliveInterval < 0 {
+		fs.Usage()
+		return nil, fmt.Errorf("keepalive interval must not be negative, got: %v", config.KeepaliveInterval)
+	}
+
+	if config.IdleTimeout < 0 {
+		fs.Usage()
+		return nil, fmt.Errorf("idle timeout must not be negative, got: %v", config.IdleTimeout)
+	}
+
+	if config.InsecureTLS && (config.CAFile != "" || len(config.PinnedSPKI) > 0) {
+		fs.Usage()
+		return nil, fmt.Errorf("-insecure cannot be combined with -ca or -pin")
+	}
+
+	if config.BatchFormat != BatchNone && config.BatchFormat != BatchNDJSON && config.BatchFormat != BatchArray {
+		fs.Usage()
+		return nil, fmt.Errorf("batch format must be %q or %q, got: %q", BatchNDJSON, BatchArray, config.BatchFormat)
+	}
+
+	if config.BatchSize < 1 || config.BatchInterval <= 0 {
+		fs.Usage()
+		return nil, fmt.Errorf("batch size and interval must be positive, got: %d, %v", config.BatchSize, config.BatchInterval)
+	}
+
+	if err := validSchema(config.Schema); err != nil {
+		fs.Usage()
+		return nil, err
+	}
+
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		fs.Usage()
+		return nil, fmt.Errorf("-cert and -key must be given together")
+	}
+
+	if config.HTTPAddr != "" {
+		if _, _, err := net.SplitHostPort(config.HTTPAddr); err != nil {
+			fs.Usage()
+			return nil, fmt.Errorf("invalid -http address '%s': %w", config.HTTPAddr, err)
+		}
+	}
+
+	if config.Control && config.HTTPAddr == "" {
+		fs.Usage()
+		return nil, fmt.Errorf("-control requires -http")
+	}
+
+	if config.CodeFile != "" && !config.Control {
+		fs.Usage()
+		return nil, fmt.Errorf("-code-file requires -control")
+	}
+
+	if config.ThreeDigitZones && !config.Control {
+		fs.Usage()
+		return nil, fmt.Errorf("-3digit-zones requires -control")
+	}
+
 	if fs.NArg()-argOffset < 1 || fs.NArg()-argOffset > 2 {
 		fs.Usage()
 		return nil, fmt.Errorf("expected 1 or 2 positional arguments, got %d", fs.NArg()-argOffset)
@@ -195,11 +329,93 @@ func parseConfig(fs *flag.FlagSet, args []string) (*Config, error) {
 	return config, nil
 }
 
+// namedReporter is a reporter tagged with its destination name for log messages
+type namedReporter struct {
+	name string
+	*AsyncReporter
+}
+
+// stateSnapshotPath is where the alarm system state is saved between runs
+const stateSnapshotPath = "./logs/state.json"
+
+// shutdownTimeout bounds how long main waits for reporters to drain on exit
+const shutdownTimeout = 8 * time.Second
+
+// apiShutdownTimeout bounds how long main waits for API requests to finish on exit
+const apiShutdownTimeout = 2 * time.Second
+
+// controlTokenEnv names the environment variable holding the control API bearer token
+const controlTokenEnv = "ALARM_MON_CONTROL_TOKEN"
 
-func setupLogging(config *Config) (*log.Logger, *log.Logger, error) {
+// newAPIHandler builds the status API, adding the control endpoints with -control
+func newAPIHandler(config *Config, store *state.Store, client *tpi.Client, logger *log.Logger) (http.Handler, error) {
+	srv := api.New(store, client.Status)
+	if !config.Control {
+		return srv, nil
+	}
+
+	token := os.Getenv(controlTokenEnv)
+	if token == "" {
+		return nil, fmt.Errorf("-control requires the %s environment variable", controlTokenEnv)
+	}
+	var code string
+	if config.CodeFile != "" {
+		var err error
+		if code, err = api.ReadCodeFile(config.CodeFile); err != nil {
+			return nil, fmt.Errorf("failed to read user code: %w", err)
+		}
+	}
+	if err := srv.EnableControl(api.ControlOptions{
+		Keypad:          client,
+		Token:           token,
+		Code:            code,
+		ThreeDigitZones: config.ThreeDigitZones,
+		Logger:          logger,
+	}); err != nil {
+		return nil, err
+	}
+	logger.Println("INFO: Control API enabled")
+	return srv, nil
+}
+
+// startStatusAPI listens on addr and serves handler in the background. The
+// returned shutdown function stops accepting requests and waits for those in flight.
+func startStatusAPI(addr string, handler http.Handler, logger *log.Logger) (func(context.Context), error) {
+	// Listen up front so a bad or busy address fails startup
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start status API: %w", err)
+	}
+
+	// Cancelled on shutdown so control requests stop waiting for confirmation
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ErrorLog:          logger,
+		BaseContext:       func(net.Listener) context.Context { return requestCtx },
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Printf("ERROR: Status API stopped: %v", err)
+		}
+	}()
+	logger.Printf("INFO: Serving status API on http://%s", ln.Addr())
+
+	return func(ctx context.Context) {
+		cancelRequests()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Printf("WARN: Status API shutdown: %v", err)
+		}
+	}, nil
+}
+
+// setupLogging builds the TPI and application loggers. The returned shutdown
+// function drains the remote reporters and closes the log files.
+func setupLogging(config *Config) (*log.Logger, *log.Logger, func(context.Context), error) {
 	// Ensure logs directory exists
 	if err := os.MkdirAll("./logs", 0755); err != nil {
-		return nil, nil, fmt.Errorf("failed to create logs directory: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to create logs directory: %w", err)
 	}
 
 	// TPI message logger (raw messages only, NO PREFIX/TIMESTAMP)
@@ -222,19 +438,45 @@ func setupLogging(config *Config) (*log.Logger, *log.Logger, error) {
 	systemID := fmt.Sprintf("%s:%d", config.EnvisaLinkIP, config.EnvisaLinkPort)
 
 	// Remote Reporters
-	// Only enabled if URL is provided and ALARM_MON_API_KEY is set
-	var tpiReporter, appReporter *AsyncReporter
-	apiKey := os.Getenv("ALARM_MON_API_KEY")
-	if config.DestinationURL != "" && apiKey != "" {
-		tpiReporter = NewAsyncReporter(config.DestinationURL, systemID, "TPI", false, appRoller)
-		appReporter = NewAsyncReporter(config.DestinationURL, systemID, "Application", true, appRoller)
+	// The command line URL is enabled only if ALARM_MON_API_KEY or a client certificate is set
+	var destinations []Destination
+	if config.DestinationURL != "" {
+		destinations = append(destinations, commandLineDestination(config))
+	}
+	if config.DestinationsFile != "" {
+		fileDestinations, err := loadDestinations(config.DestinationsFile)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		destinations = append(destinations, fileDestinations...)
+	}
+
+	var tpiReporters, appReporters []namedReporter
+	for _, d := range destinations {
+		for _, messageType := range d.MessageTypes {
+			r, err := NewAsyncReporterWithOptions(d.URL, systemID, messageType, messageType == "Application", appRoller, d.reporterOptions(messageType))
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to create %s reporter for destination %s: %w", messageType, d.Name, err)
+			}
+			if r == nil {
+				if d.Name != defaultDestination {
+					return nil, nil, nil, fmt.Errorf("destination %s: no API key in $%s and no client certificate", d.Name, d.APIKeyEnv)
+				}
+				continue
+			}
+			if messageType == "TPI" {
+				tpiReporters = append(tpiReporters, namedReporter{d.Name, r})
+			} else {
+				appReporters = append(appReporters, namedReporter{d.Name, r})
+			}
+		}
 	}
 
 	// TPI Writer Construction
 	var tpiWriters []io.Writer
 	tpiWriters = append(tpiWriters, tpiRoller)
-	if tpiReporter != nil {
-		tpiWriters = append(tpiWriters, tpiReporter)
+	for _, r := range tpiReporters {
+		tpiWriters = append(tpiWriters, r)
 	}
 
 	if config.Verbose {
@@ -246,8 +488,8 @@ func setupLogging(config *Config) (*log.Logger, *log.Logger, error) {
 	// App Writer Construction
 	var appWriters []io.Writer
 	appWriters = append(appWriters, appRoller)
-	if appReporter != nil {
-		appWriters = append(appWriters, appReporter)
+	for _, r := range appReporters {
+		appWriters = append(appWriters, r)
 	}
 
 	if config.Verbose {
@@ -256,5 +498,29 @@ func setupLogging(config *Config) (*log.Logger, *log.Logger, error) {
 	appWriter := io.MultiWriter(appWriters...)
 	appLogger := log.New(appWriter, "", log.LstdFlags)
 
-	return tpiLogger, appLogger, nil
+	shutdown := func(ctx context.Context) {
+		// The reporters are closed, so report shutdown results to the log file only
+		shutdownLogger := log.New(appRoller, "", log.LstdFlags)
+
+		// Drain all destinations in parallel so a slow one cannot use up the others' time
+		var wg sync.WaitGroup
+		for _, r := range append(tpiReporters, appReporters...) {
+			wg.Add(1)
+			go func(r namedReporter) {
+				defer wg.Done()
+				lost, err := r.Close(ctx)
+				if err != nil {
+					shutdownLogger.Printf("WARN: %s reporter for %s did not drain before shutdown: %v", r.messageType, r.name, err)
+				}
+				if lost > 0 {
+					shutdownLogger.Printf("WARN: %s reporter for %s lost %d events during shutdown", r.messageType, r.name, lost)
+				}
+			}(r)
+		}
+		wg.Wait()
+		tpiRoller.Close()
+		appRoller.Close()
+	}
+
+	return tpiLogger, appLogger, shutdown, nil
 }
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"envisaMon/tpi"
 )
 
 func TestParseArgs(t *testing.T) {
@@ -21,9 +29,14 @@ func TestParseArgs(t *testing.T) {
 			name: "valid IP only",
 			args: []string{"192.168.1.100"},
 			wantConfig: &Config{
-				EnvisaLinkIP:     "192.168.1.100",
-				EnvisaLinkPort:   4025,
-				DeduplicateLimit: -1,
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
 			},
 			wantErr: false,
 		},
@@ -31,9 +44,14 @@ func TestParseArgs(t *testing.T) {
 			name: "valid IP and port",
 			args: []string{"192.168.1.100:4026"},
 			wantConfig: &Config{
-				EnvisaLinkIP:     "192.168.1.100",
-				EnvisaLinkPort:   4026,
-				DeduplicateLimit: -1,
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4026,
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
 			},
 			wantErr: false,
 		},
@@ -41,11 +59,16 @@ func TestParseArgs(t *testing.T) {
 			name: "valid IP and URL",
 			args: []string{"192.168.1.100", "https://events.example.com/api"},
 			wantConfig: &Config{
-				EnvisaLinkIP:     "192.168.1.100",
-				EnvisaLinkPort:   4025,
-				DestinationURL:   "https://events.example.com/api",
-				DestinationPath:  "/api",
-				DeduplicateLimit: -1,
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DestinationURL:    "https://events.example.com/api",
+				DestinationPath:   "/api",
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
 			},
 			wantErr: false,
 		},
@@ -53,11 +76,16 @@ func TestParseArgs(t *testing.T) {
 			name: "valid IP, port, and URL",
 			args: []string{"192.168.1.100:4026", "https://events.example.com:8080/webhook"},
 			wantConfig: &Config{
-				EnvisaLinkIP:     "192.168.1.100",
-				EnvisaLinkPort:   4026,
-				DestinationURL:   "https://events.example.com:8080/webhook",
-				DestinationPath:  "/webhook",
-				DeduplicateLimit: -1,
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4026,
+				DestinationURL:    "https://events.example.com:8080/webhook",
+				DestinationPath:   "/webhook",
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
 			},
 			wantErr: false,
 		},
@@ -65,11 +93,16 @@ func TestParseArgs(t *testing.T) {
 			name: "flags and valid arguments",
 			args: []string{"-v", "-u", "192.168.1.100"},
 			wantConfig: &Config{
-				EnvisaLinkIP:     "192.168.1.100",
-				EnvisaLinkPort:   4025,
-				Verbose:          true,
-				Deduplicate:      true,
-				DeduplicateLimit: 0,
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				Verbose:           true,
+				Deduplicate:       true,
+				DeduplicateLimit:  0,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
 			},
 			wantErr: false,
 		},
@@ -77,26 +110,335 @@ func TestParseArgs(t *testing.T) {
 			name: "deduplicate with limit",
 			args: []string{"-u", "50", "192.168.1.100"},
 			wantConfig: &Config{
-				EnvisaLinkIP:     "192.168.1.100",
-				EnvisaLinkPort:   4025,
-				Deduplicate:      true,
-				DeduplicateLimit: 50,
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				Deduplicate:       true,
+				DeduplicateLimit:  50,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
 			},
 			wantErr: false,
 		},
 		{
 			name: "deduplicate with limit and URL",
 			args: []string{"-u", "100", "192.168.1.100", "https://api.example.com/v1"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DestinationURL:    "https://api.example.com/v1",
+				DestinationPath:   "/v1",
+				Deduplicate:       true,
+				DeduplicateLimit:  100,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+			},
+			wantErr: false,
+		},
+		{
+			name: "custom keepalive interval",
+			args: []string{"-k", "2m", "192.168.1.100"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: 2 * time.Minute,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+			},
+			wantErr: false,
+		},
+		{
+			name: "keepalive disabled",
+			args: []string{"-k", "0", "192.168.1.100"},
 			wantConfig: &Config{
 				EnvisaLinkIP:     "192.168.1.100",
 				EnvisaLinkPort:   4025,
-				DestinationURL:   "https://api.example.com/v1",
-				DestinationPath:  "/v1",
-				Deduplicate:      true,
-				DeduplicateLimit: 100,
+				DeduplicateLimit: -1,
+				IdleTimeout:      tpi.DefaultIdleTimeout,
+				BatchSize:        DefaultBatchSize,
+				BatchInterval:    DefaultBatchInterval,
+				Schema:           SchemaV1,
+			},
+			wantErr: false,
+		},
+		{
+			name:        "negative keepalive interval",
+			args:        []string{"-k", "-1m", "192.168.1.100"},
+			wantErr:     true,
+			errContains: "keepalive interval must not be negative",
+			wantUsage:   true,
+		},
+		{
+			name: "custom idle timeout",
+			args: []string{"-w", "30s", "192.168.1.100"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       30 * time.Second,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+			},
+			wantErr: false,
+		},
+		{
+			name:        "negative idle timeout",
+			args:        []string{"-w", "-5s", "192.168.1.100"},
+			wantErr:     true,
+			errContains: "idle timeout must not be negative",
+			wantUsage:   true,
+		},
+		{
+			name: "spool enabled",
+			args: []string{"-s", "192.168.1.100", "https://api.example.com/v1"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DestinationURL:    "https://api.example.com/v1",
+				DestinationPath:   "/v1",
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+				Spool:             true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "custom CA and pins",
+			args: []string{"-ca", "/etc/envisamon/ca.pem", "-pin", "abc=", "-pin", "def=", "192.168.1.100", "https://api.example.com/v1"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DestinationURL:    "https://api.example.com/v1",
+				DestinationPath:   "/v1",
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+				CAFile:            "/etc/envisamon/ca.pem",
+				PinnedSPKI:        []string{"abc=", "def="},
+			},
+			wantErr: false,
+		},
+		{
+			name: "insecure TLS",
+			args: []string{"-insecure", "192.168.1.100", "https://api.example.com/v1"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DestinationURL:    "https://api.example.com/v1",
+				DestinationPath:   "/v1",
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+				InsecureTLS:       true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "client certificate",
+			args: []string{"-cert", "client.pem", "-key", "client-key.pem", "192.168.1.100", "https://api.example.com/v1"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DestinationURL:    "https://api.example.com/v1",
+				DestinationPath:   "/v1",
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+				ClientCertFile:    "client.pem",
+				ClientKeyFile:     "client-key.pem",
+			},
+			wantErr: false,
+		},
+		{
+			name:        "client certificate without key",
+			args:        []string{"-cert", "client.pem", "192.168.1.100", "https://api.example.com/v1"},
+			wantErr:     true,
+			errContains: "-cert and -key must be given together",
+			wantUsage:   true,
+		},
+		{
+			name: "batching with gzip",
+			args: []string{"-batch", "ndjson", "-batch-size", "50", "-batch-interval", "10s", "-gzip", "192.168.1.100", "https://api.example.com/v1"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DestinationURL:    "https://api.example.com/v1",
+				DestinationPath:   "/v1",
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchFormat:       BatchNDJSON,
+				BatchSize:         50,
+				BatchInterval:     10 * time.Second,
+				Gzip:              true,
+				Schema:            SchemaV1,
+			},
+			wantErr: false,
+		},
+		{
+			name:        "unknown batch format",
+			args:        []string{"-batch", "xml", "192.168.1.100"},
+			wantErr:     true,
+			errContains: "batch format must be",
+			wantUsage:   true,
+		},
+		{
+			name:        "zero batch size",
+			args:        []string{"-batch-size", "0", "192.168.1.100"},
+			wantErr:     true,
+			errContains: "batch size and interval must be positive",
+			wantUsage:   true,
+		},
+		{
+			name: "schema v2",
+			args: []string{"-schema", "2", "192.168.1.100", "https://api.example.com/v1"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DestinationURL:    "https://api.example.com/v1",
+				DestinationPath:   "/v1",
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV2,
+			},
+			wantErr: false,
+		},
+		{
+			name: "destinations file without URL",
+			args: []string{"-destinations", "/etc/envisamon/destinations.json", "192.168.1.100"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+				DestinationsFile:  "/etc/envisamon/destinations.json",
+			},
+			wantErr: false,
+		},
+		{
+			name: "status API address",
+			args: []string{"-http", "127.0.0.1:8080", "192.168.1.100"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+				HTTPAddr:          "127.0.0.1:8080",
+			},
+			wantErr: false,
+		},
+		{
+			name: "control API with code file",
+			args: []string{"-http", "127.0.0.1:8080", "-control", "-code-file", "/run/secrets/alarm-code", "192.168.1.100"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+				HTTPAddr:          "127.0.0.1:8080",
+				Control:           true,
+				CodeFile:          "/run/secrets/alarm-code",
+			},
+			wantErr: false,
+		},
+		{
+			name:        "control API without http",
+			args:        []string{"-control", "192.168.1.100"},
+			wantErr:     true,
+			errContains: "-control requires -http",
+			wantUsage:   true,
+		},
+		{
+			name: "control API with 3 digit zones",
+			args: []string{"-http", "127.0.0.1:8080", "-control", "-3digit-zones", "192.168.1.100"},
+			wantConfig: &Config{
+				EnvisaLinkIP:      "192.168.1.100",
+				EnvisaLinkPort:    4025,
+				DeduplicateLimit:  -1,
+				KeepaliveInterval: tpi.DefaultKeepaliveInterval,
+				IdleTimeout:       tpi.DefaultIdleTimeout,
+				BatchSize:         DefaultBatchSize,
+				BatchInterval:     DefaultBatchInterval,
+				Schema:            SchemaV1,
+				HTTPAddr:          "127.0.0.1:8080",
+				Control:           true,
+				ThreeDigitZones:   true,
 			},
 			wantErr: false,
 		},
+		{
+			name:        "3 digit zones without control",
+			args:        []string{"-3digit-zones", "192.168.1.100"},
+			wantErr:     true,
+			errContains: "-3digit-zones requires -control",
+			wantUsage:   true,
+		},
+		{
+			name:        "code file without control",
+			args:        []string{"-http", "127.0.0.1:8080", "-code-file", "/run/secrets/alarm-code", "192.168.1.100"},
+			wantErr:     true,
+			errContains: "-code-file requires -control",
+			wantUsage:   true,
+		},
+		{
+			name:        "status API address without port",
+			args:        []string{"-http", "127.0.0.1", "192.168.1.100"},
+			wantErr:     true,
+			errContains: "invalid -http address",
+			wantUsage:   true,
+		},
+		{
+			name:        "unknown schema",
+			args:        []string{"-schema", "3", "192.168.1.100"},
+			wantErr:     true,
+			errContains: "unknown event schema version",
+			wantUsage:   true,
+		},
+		{
+			name:        "insecure with pin",
+			args:        []string{"-insecure", "-pin", "abc=", "192.168.1.100", "https://api.example.com/v1"},
+			wantErr:     true,
+			errContains: "-insecure cannot be combined",
+			wantUsage:   true,
+		},
 		{
 			name:        "no arguments",
 			args:        []string{},
@@ -191,13 +533,13 @@ func TestSetupLogging(t *testing.T) {
 	// Since setupLogging has hardcoded "./logs", we might need to change directory or mock it.
 	// For now, let's just test that it runs without error if the directory exists.
 	// In a real scenario, we'd refactor setupLogging to accept a base path.
-	
+
 	// Temporarily change working directory to temp dir
 	oldWd, _ := os.Getwd()
 	os.Chdir(tmpDir)
 	defer os.Chdir(oldWd)
 
-	tpiLogger, appLogger, err := setupLogging(config)
+	tpiLogger, appLogger, shutdown, err := setupLogging(config)
 	if err != nil {
 		t.Errorf("setupLogging() error = %v", err)
 	}
@@ -216,4 +558,53 @@ func TestSetupLogging(t *testing.T) {
 	if _, err := os.Stat("logs/application.log"); os.IsNotExist(err) {
 		t.Error("application.log was not created")
 	}
-}
\ No newline at end of file
+
+	shutdown(context.Background())
+}
+
+func TestStartStatusAPI_ShutdownCancelsRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// A request that waits, like a control request waiting for the panel
+	waiting := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(waiting)
+		select {
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case <-time.After(10 * time.Second):
+		}
+	})
+	shutdown, err := startStatusAPI(addr, handler, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("startStatusAPI() error = %v", err)
+	}
+
+	codes := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			codes <- 0
+			return
+		}
+		resp.Body.Close()
+		codes <- resp.StatusCode
+	}()
+	<-waiting
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	shutdown(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("shutdown took %v, want in-flight requests cancelled", elapsed)
+	}
+	if code := <-codes; code != http.StatusServiceUnavailable {
+		t.Errorf("in-flight request status = %d, want 503", code)
+	}
+}
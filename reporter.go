@@ -2,14 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
-	"crypto/tls"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,12 +21,28 @@ type Event struct {
 	EventMessage  string `json:"event_message"`
 	MessageType   string `json:"message_type"`
 	SystemID      string `json:"system_id"`
+
+	// Schema v2 fields, see schema.go
+	SchemaVersion   int        `json:"schema_version,omitempty"`
+	Sequence        uint64     `json:"sequence,omitempty"`
+	BootID          string     `json:"boot_id,omitempty"`
+	PacketType      string     `json:"packet_type,omitempty"`
+	CommandCode     string     `json:"command_code,omitempty"`
+	Partition       int        `json:"partition,omitempty"`
+	Zone            int        `json:"zone,omitempty"`
+	User            int        `json:"user,omitempty"`
+	OpenZones       []int      `json:"open_zones,omitempty"`
+	PartitionStates []string   `json:"partition_states,omitempty"`
+	KeypadText      string     `json:"keypad_text,omitempty"`
+	LEDs            *LEDFlags  `json:"leds,omitempty"`
+	CID             *CIDFields `json:"cid,omitempty"`
 }
 
 // reportedMessage wraps a log message with its arrival timestamp
 type reportedMessage struct {
 	content   string
 	timestamp time.Time
+	sequence  uint64 // Assigned on arrival so v2 sequence numbers follow log order
 }
 
 // AsyncReporter implements io.Writer to intercept logs and send them to a remote API
@@ -37,19 +54,118 @@ type AsyncReporter struct {
 	stripTimestamp bool
 	client         *http.Client
 	msgChan        chan reportedMessage
-	errorWriter    io.Writer // Writer to log internal errors (e.g., file writer)
+	errorWriter    io.Writer     // Writer to log internal errors (e.g., file writer)
+	spool          *Spool        // When set, msgChan feeds the spool writer instead of the workers
+	spoolWritten   chan struct{} // Closed when the spool writer has appended everything queued
+	deadLetter     *deadLetterFile
+	maxAttempts    int
+	signingKey     []byte // When set, requests carry an HMAC signature
+	schema         int
+	packetTypes    map[string]bool // When set, only TPI lines of these packet types are reported
+	sequence       atomic.Uint64
+
+	// Batching, see batch.go
+	batchFormat   string
+	batchSize     int
+	batchInterval time.Duration
+	batchChan     chan []Event
+	gzip          bool
+
+	// Shutdown state
+	mu      sync.RWMutex // Guards closed against concurrent Writes
+	closed  bool
+	closing chan struct{} // Closed by Close to tell the spool sender to drain and stop
+	workers sync.WaitGroup
+	ctx     context.Context // Cancelled when Close gives up waiting
+	cancel  context.CancelFunc
+	lost    atomic.Int64 // Messages dropped because of shutdown
+}
+
+// ReporterOptions holds optional AsyncReporter settings
+type ReporterOptions struct {
+	// SpoolPath enables a durable on-disk queue. Events are written there
+	// before sending and replayed in order, across restarts, until accepted.
+	SpoolPath string
+
+	// DeadLetterPath is where events permanently rejected with a 4xx status
+	// are appended. If empty they are only logged.
+	DeadLetterPath string
+
+	// MaxAttempts bounds delivery attempts per event when no spool is
+	// configured (default 5). Spooled events are retried until accepted.
+	MaxAttempts int
+
+	// CAFile is a PEM bundle used instead of the system roots to verify the API server
+	CAFile string
+
+	// PinnedSPKI lists base64 SHA-256 hashes of acceptable server public keys
+	PinnedSPKI []string
+
+	// InsecureSkipVerify disables certificate verification entirely. A warning is logged.
+	InsecureSkipVerify bool
+
+	// ClientCertFile and ClientKeyFile are a PEM key pair presented for mutual
+	// TLS. They are reloaded when either file changes.
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// SigningKey enables HMAC-SHA256 request signing, see signRequest
+	SigningKey []byte
+
+	// BatchFormat sends several events per request as BatchNDJSON or
+	// BatchArray. The default, BatchNone, sends one Event object per request.
+	BatchFormat string
+
+	// BatchSize and BatchInterval flush a batch once it holds this many events
+	// or this long has passed (defaults DefaultBatchSize and DefaultBatchInterval)
+	BatchSize     int
+	BatchInterval time.Duration
+
+	// Gzip compresses request bodies with Content-Encoding: gzip
+	Gzip bool
+
+	// Schema selects the Event payload version, SchemaV1 (default) or SchemaV2
+	Schema int
+
+	// APIKeyEnv names the environment variable holding the API key
+	// (default ALARM_MON_API_KEY)
+	APIKeyEnv string
+
+	// PacketTypes, when set, limits reporting to TPI lines that parse as one
+	// of these packet types (see the Packet* constants)
+	PacketTypes []string
 }
 
 // NewAsyncReporter creates a new reporter. Returns nil if url or ALARM_MON_API_KEY is empty.
 func NewAsyncReporter(url, systemID, messageType string, stripTimestamp bool, errorWriter io.Writer) *AsyncReporter {
-	apiKey := os.Getenv("ALARM_MON_API_KEY")
-	if url == "" || apiKey == "" {
+	ar, err := NewAsyncReporterWithOptions(url, systemID, messageType, stripTimestamp, errorWriter, ReporterOptions{})
+	if err != nil {
+		fmt.Fprintf(errorWriter, "AsyncReporter setup error: %v\n", err)
 		return nil
 	}
+	return ar
+}
+
+// NewAsyncReporterWithOptions is NewAsyncReporter with optional settings.
+// Returns nil and no error if url is empty, or if neither an API key (from
+// ALARM_MON_API_KEY or opts.APIKeyEnv) nor a client certificate is available
+// to authenticate with.
+func NewAsyncReporterWithOptions(url, systemID, messageType string, stripTimestamp bool, errorWriter io.Writer, opts ReporterOptions) (*AsyncReporter, error) {
+	apiKeyEnv := opts.APIKeyEnv
+	if apiKeyEnv == "" {
+		apiKeyEnv = "ALARM_MON_API_KEY"
+	}
+	apiKey := os.Getenv(apiKeyEnv)
+	if url == "" || (apiKey == "" && opts.ClientCertFile == "") {
+		return nil, nil
+	}
 
-	// Configure transport to skip SSL verification for self-signed certificates
+	tlsConfig, err := newTLSConfig(url, opts, errorWriter)
+	if err != nil {
+		return nil, err
+	}
 	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig: tlsConfig,
 	}
 
 	ar := &AsyncReporter{
@@ -64,12 +180,79 @@ func NewAsyncReporter(url, systemID, messageType string, stripTimestamp bool, er
 		},
 		msgChan:     make(chan reportedMessage, 500), // Buffer to avoid blocking main thread
 		errorWriter: errorWriter,
+		closing:     make(chan struct{}),
+		maxAttempts: opts.MaxAttempts,
+		signingKey:  opts.SigningKey,
+		batchFormat: opts.BatchFormat,
+		batchSize:   1,
+		gzip:        opts.Gzip,
+		schema:      opts.Schema,
+	}
+	if ar.schema == 0 {
+		ar.schema = SchemaV1
+	}
+	if err := validSchema(ar.schema); err != nil {
+		return nil, err
+	}
+	if len(opts.PacketTypes) > 0 {
+		ar.packetTypes = make(map[string]bool, len(opts.PacketTypes))
+		for _, pt := range opts.PacketTypes {
+			ar.packetTypes[pt] = true
+		}
+	}
+	if ar.maxAttempts <= 0 {
+		ar.maxAttempts = defaultMaxAttempts
+	}
+	switch opts.BatchFormat {
+	case BatchNone:
+	case BatchNDJSON, BatchArray:
+		ar.batchSize = opts.BatchSize
+		if ar.batchSize <= 0 {
+			ar.batchSize = DefaultBatchSize
+		}
+		ar.batchInterval = opts.BatchInterval
+		if ar.batchInterval <= 0 {
+			ar.batchInterval = DefaultBatchInterval
+		}
+	default:
+		return nil, fmt.Errorf("unknown batch format %q", opts.BatchFormat)
+	}
+	if opts.DeadLetterPath != "" {
+		ar.deadLetter = &deadLetterFile{path: opts.DeadLetterPath}
+	}
+	ar.ctx, ar.cancel = context.WithCancel(context.Background())
+
+	if opts.SpoolPath != "" {
+		spool, err := OpenSpool(opts.SpoolPath, 0)
+		if err != nil {
+			return nil, err
+		}
+		ar.spool = spool
+		ar.spoolWritten = make(chan struct{})
+
+		// A single writer and a single sender keep delivery in spool order
+		ar.workers.Add(2)
+		go ar.spoolWriter()
+		go ar.spoolSender()
+		return ar, nil
+	}
+
+	if ar.batchFormat != BatchNone {
+		ar.batchChan = make(chan []Event, 4)
+		ar.workers.Add(1)
+		go ar.batcher()
+		for i := 0; i < 4; i++ {
+			ar.workers.Add(1)
+			go ar.batchWorker()
+		}
+		return ar, nil
 	}
 
 	for i := 0; i < 4; i++ {
+		ar.workers.Add(1)
 		go ar.worker()
 	}
-	return ar
+	return ar, nil
 }
 
 // Write implements io.Writer. It parses the log line and queues it for sending.
@@ -77,9 +260,29 @@ func (ar *AsyncReporter) Write(p []byte) (n int, err error) {
 	msg := string(p)
 	ts := time.Now()
 
+	if ar.packetTypes != nil && !ar.packetTypes[packetType(strings.TrimSpace(msg))] {
+		// Filtered out for this destination
+		return len(p), nil
+	}
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+	if ar.closed {
+		ar.lost.Add(1)
+		return len(p), nil
+	}
+	rm := reportedMessage{content: msg, timestamp: ts, sequence: ar.sequence.Add(1)}
+
+	if ar.spool != nil {
+		// Spooled events are never dropped. The spool writer only waits on the
+		// disk, so this blocks only while it catches up with a full queue.
+		ar.msgChan <- rm
+		return len(p), nil
+	}
+
 	// Queue the message non-blocking (drop if full to avoid halting application)
 	select {
-	case ar.msgChan <- reportedMessage{content: msg, timestamp: ts}:
+	case ar.msgChan <- rm:
 	default:
 		// Channel full, drop message or log error to errorWriter
 		fmt.Fprintf(ar.errorWriter, "AsyncReporter channel full, dropping message: %s", msg)
@@ -89,12 +292,225 @@ func (ar *AsyncReporter) Write(p []byte) (n int, err error) {
 }
 
 func (ar *AsyncReporter) worker() {
+	defer ar.workers.Done()
 	for rm := range ar.msgChan {
-		ar.report(rm)
+		if ar.ctx.Err() != nil {
+			// Close gave up waiting, discard what is left
+			ar.lost.Add(1)
+			continue
+		}
+		if err := ar.report(rm); err != nil && ar.ctx.Err() != nil {
+			ar.lost.Add(1)
+		}
 	}
 }
 
-func (ar *AsyncReporter) report(rm reportedMessage) {
+// spoolWriter appends queued messages to the spool, syncing once per burst so
+// the caller of Write only waits for a slow disk once the queue is full
+func (ar *AsyncReporter) spoolWriter() {
+	defer ar.workers.Done()
+	defer close(ar.spoolWritten)
+
+	for rm := range ar.msgChan {
+		ar.spoolAppend(rm)
+	burst:
+		for {
+			select {
+			case rm, ok := <-ar.msgChan:
+				if !ok {
+					break burst
+				}
+				ar.spoolAppend(rm)
+			default:
+				break burst
+			}
+		}
+		if err := ar.spool.Sync(); err != nil {
+			fmt.Fprintf(ar.errorWriter, "AsyncReporter spool error: %v\n", err)
+		}
+	}
+}
+
+// spoolAppend adds one message to the spool, reporting failures to errorWriter
+func (ar *AsyncReporter) spoolAppend(rm reportedMessage) {
+	if err := ar.spool.Append(ar.newEvent(rm)); err != nil {
+		fmt.Fprintf(ar.errorWriter, "AsyncReporter spool error, dropping message: %v: %s", err, rm.content)
+	}
+}
+
+// spoolSender delivers spooled events oldest first. The head of the queue is
+// retried until the API accepts or permanently rejects it. In batch mode a
+// partial batch is held back until the batch interval passes.
+func (ar *AsyncReporter) spoolSender() {
+	defer ar.workers.Done()
+
+	var flushAt time.Time
+	readFailures := 0
+	for {
+		events, next, err := ar.spool.PeekBatch(ar.batchSize)
+		if err != nil && next > ar.spool.acked() {
+			fmt.Fprintf(ar.errorWriter, "AsyncReporter spool error, skipping record: %v\n", err)
+			if err := ar.spool.Ack(next); err != nil {
+				fmt.Fprintf(ar.errorWriter, "AsyncReporter spool error: %v\n", err)
+			}
+			continue
+		}
+		if err != nil {
+			// Nothing to skip past, so wait before reading again
+			readFailures++
+			fmt.Fprintf(ar.errorWriter, "AsyncReporter spool error: %v\n", err)
+			select {
+			case <-time.After(retryDelay(readFailures, err)):
+				continue
+			case <-ar.closing:
+				// Leave the rest for the next run
+				return
+			case <-ar.ctx.Done():
+				return
+			}
+		}
+		readFailures = 0
+
+		if len(events) == 0 {
+			// Spool drained, wait for more or for shutdown
+			select {
+			case <-ar.spool.Notify():
+				continue
+			case <-ar.spoolWritten:
+				// Shutting down; stop once the writer's last appends are sent
+				if ar.spool.Pending() == 0 {
+					return
+				}
+				continue
+			case <-ar.ctx.Done():
+				return
+			}
+		}
+
+		if len(events) < ar.batchSize && !ar.isClosing() {
+			if flushAt.IsZero() {
+				flushAt = time.Now().Add(ar.batchInterval)
+			}
+			if wait := time.Until(flushAt); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ar.spool.Notify():
+					timer.Stop()
+					continue
+				case <-timer.C:
+				case <-ar.closing:
+					timer.Stop()
+				case <-ar.ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+		}
+		flushAt = time.Time{}
+
+		if err := ar.deliver(events, 0); err != nil && !isPermanent(err) {
+			// Only cancellation stops unlimited retries; keep the events for next run
+			return
+		}
+
+		if err := ar.spool.Ack(next); err != nil {
+			fmt.Fprintf(ar.errorWriter, "AsyncReporter spool error: %v\n", err)
+		}
+	}
+}
+
+// isClosing reports whether Close has been called
+func (ar *AsyncReporter) isClosing() bool {
+	select {
+	case <-ar.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting new messages and waits for queued ones to be sent.
+// If ctx expires first, in-flight requests are aborted and the remaining
+// queue is discarded. It returns how many messages were lost to shutdown.
+// Spooled events are never counted as lost; they are replayed on next start.
+func (ar *AsyncReporter) Close(ctx context.Context) (int, error) {
+	ar.mu.Lock()
+	if ar.closed {
+		ar.mu.Unlock()
+		return int(ar.lost.Load()), nil
+	}
+	ar.closed = true
+	close(ar.msgChan)
+	close(ar.closing)
+	ar.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		ar.workers.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		ar.cancel()
+		<-done
+	}
+	ar.cancel()
+
+	if ar.spool != nil {
+		ar.spool.Close()
+	}
+
+	return int(ar.lost.Load()), err
+}
+
+// report sends a single message to the API, returning an error if it was not accepted
+func (ar *AsyncReporter) report(rm reportedMessage) error {
+	return ar.deliver([]Event{ar.newEvent(rm)}, ar.maxAttempts)
+}
+
+// deliver sends events in one request, retrying transient failures with
+// backoff. maxAttempts of 0 retries until success, a permanent failure or
+// shutdown. Permanently rejected events are written to the dead-letter file.
+func (ar *AsyncReporter) deliver(events []Event, maxAttempts int) error {
+	for attempt := 1; ; attempt++ {
+		err := ar.send(events)
+		if err == nil {
+			return nil
+		}
+
+		if isPermanent(err) {
+			if ar.deadLetter == nil {
+				fmt.Fprintf(ar.errorWriter, "AsyncReporter dropping %d event(s) that cannot be delivered: %v\n", len(events), err)
+				return err
+			}
+			for _, event := range events {
+				if dlErr := ar.deadLetter.write(event, err); dlErr != nil {
+					fmt.Fprintf(ar.errorWriter, "AsyncReporter dead-letter error: %v\n", dlErr)
+				}
+			}
+			return err
+		}
+
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			fmt.Fprintf(ar.errorWriter, "AsyncReporter giving up on %d event(s) after %d attempts\n", len(events), attempt)
+			return err
+		}
+
+		delay := retryDelay(attempt, err)
+		select {
+		case <-time.After(delay):
+		case <-ar.ctx.Done():
+			return err
+		}
+	}
+}
+
+// newEvent builds the API payload for a log message
+func (ar *AsyncReporter) newEvent(rm reportedMessage) Event {
 	// Prepare message
 	cleanMsg := rm.content
 	if ar.stripTimestamp {
@@ -114,35 +530,60 @@ func (ar *AsyncReporter) report(rm reportedMessage) {
 		MessageType:   ar.messageType,
 		SystemID:      ar.systemID,
 	}
+	if ar.schema == SchemaV2 {
+		event.SchemaVersion = SchemaV2
+		event.Sequence = rm.sequence
+		event.BootID = bootID
+		if ar.messageType == "TPI" {
+			decodeTPI(&event, cleanMsg)
+		}
+	}
+	return event
+}
 
-	payload, err := json.Marshal(event)
+// send POSTs events in a single request, returning an error if they were not accepted
+func (ar *AsyncReporter) send(events []Event) error {
+	payload, contentType, err := ar.encodeEvents(events)
 	if err != nil {
 		fmt.Fprintf(ar.errorWriter, "AsyncReporter marshal error: %v\n", err)
-		return
+		return err
 	}
 
 	// Send request
-	req, err := http.NewRequest("POST", ar.url, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ar.ctx, "POST", ar.url, bytes.NewBuffer(payload))
 	if err != nil {
 		fmt.Fprintf(ar.errorWriter, "AsyncReporter request creation error: %v\n", err)
-		return
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if ar.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
 	}
-	req.Header.Set("Content-Type", "application/json")
 	if ar.apiKey != "" {
 		req.Header.Set("X-API-Key", ar.apiKey)
 	}
+	if len(ar.signingKey) > 0 {
+		signRequest(req, ar.signingKey, payload, time.Now())
+	}
 
 	resp, err := ar.client.Do(req)
 	if err != nil {
 		fmt.Fprintf(ar.errorWriter, "AsyncReporter request error: %v\n", err)
-		return
+		return &deliveryError{Err: err}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	// Any 2xx means the event was accepted
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		body, _ := io.ReadAll(resp.Body)
 		fmt.Fprintf(ar.errorWriter, "AsyncReporter API error: %d - %s\n", resp.StatusCode, string(body))
+		de := &deliveryError{StatusCode: resp.StatusCode, Body: string(body)}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			de.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return de
 	}
+	return nil
 }
 
 func newUUID() string {
@@ -1,17 +1,38 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
+// trustServer returns reporter options that verify against the test server's certificate
+func trustServer(t *testing.T, ts *httptest.Server) ReporterOptions {
+	t.Helper()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemData, 0600); err != nil {
+		t.Fatalf("writing CA file: %v", err)
+	}
+	return ReporterOptions{CAFile: caFile}
+}
+
+func withSpool(opts ReporterOptions, path string) ReporterOptions {
+	opts.SpoolPath = path
+	return opts
+}
+
 func TestAsyncReporter_TimestampResolution(t *testing.T) {
 	// Set API key for NewAsyncReporter
 	os.Setenv("ALARM_MON_API_KEY", "test-key")
@@ -27,9 +48,9 @@ func TestAsyncReporter_TimestampResolution(t *testing.T) {
 	defer ts.Close()
 
 	errorWriter := &strings.Builder{}
-	reporter := NewAsyncReporter(ts.URL, "test-system", "TPI", false, errorWriter)
-	if reporter == nil {
-		t.Fatal("Failed to create AsyncReporter")
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, errorWriter, trustServer(t, ts))
+	if err != nil || reporter == nil {
+		t.Fatalf("Failed to create AsyncReporter: %v", err)
 	}
 
 	// Write a message
@@ -72,3 +93,314 @@ func TestAsyncReporter_TimestampResolution(t *testing.T) {
 		t.Errorf("Internal reporter errors: %s", errorWriter.String())
 	}
 }
+
+func TestAsyncReporter_CloseDrainsQueue(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	var received atomic.Int64
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	reporter, _ := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, trustServer(t, ts))
+	for i := 0; i < 20; i++ {
+		reporter.Write([]byte(fmt.Sprintf("message %d", i)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	lost, err := reporter.Close(ctx)
+	if err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if lost != 0 {
+		t.Errorf("Close() lost = %d, want 0", lost)
+	}
+	if got := received.Load(); got != 20 {
+		t.Errorf("server received %d events, want 20", got)
+	}
+
+	// Writes after Close are dropped and counted
+	reporter.Write([]byte("too late"))
+	if lost, _ := reporter.Close(ctx); lost != 1 {
+		t.Errorf("second Close() lost = %d, want 1", lost)
+	}
+}
+
+func TestAsyncReporter_CloseDeadline(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	release := make(chan struct{})
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	reporter, _ := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, trustServer(t, ts))
+	for i := 0; i < 10; i++ {
+		reporter.Write([]byte(fmt.Sprintf("message %d", i)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	lost, err := reporter.Close(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want context.DeadlineExceeded", err)
+	}
+	if lost != 10 {
+		t.Errorf("Close() lost = %d, want 10", lost)
+	}
+}
+
+func TestAsyncReporter_SpoolReplaysInOrder(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	origBase, origMax := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = 5*time.Millisecond, 20*time.Millisecond
+	defer func() { retryBaseDelay, retryMaxDelay = origBase, origMax }()
+
+	// The API is down for the first few requests
+	var calls atomic.Int64
+	delivered := make(chan string, 10)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		delivered <- event.EventMessage
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	spoolPath := filepath.Join(t.TempDir(), "spool.jsonl")
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, withSpool(trustServer(t, ts), spoolPath))
+	if err != nil {
+		t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+	}
+
+	want := []string{"burglary", "restore", "armed"}
+	for _, msg := range want {
+		reporter.Write([]byte(msg))
+	}
+
+	for i, w := range want {
+		select {
+		case got := <-delivered:
+			if got != w {
+				t.Errorf("delivery %d = %q, want %q", i, got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for delivery %d", i)
+		}
+	}
+
+	lost, err := reporter.Close(context.Background())
+	if err != nil || lost != 0 {
+		t.Errorf("Close() = %d, %v, want 0, nil", lost, err)
+	}
+}
+
+func TestAsyncReporter_SpoolKeepsUndeliveredOnClose(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	spoolPath := filepath.Join(t.TempDir(), "spool.jsonl")
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, withSpool(trustServer(t, ts), spoolPath))
+	if err != nil {
+		t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+	}
+	reporter.Write([]byte("burglary"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if lost, _ := reporter.Close(ctx); lost != 0 {
+		t.Errorf("Close() lost = %d, spooled events should not count as lost", lost)
+	}
+
+	// The event is still on disk for the next run
+	s, err := OpenSpool(spoolPath, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer s.Close()
+	if events, _, _ := s.PeekBatch(1); len(events) != 1 || events[0].EventMessage != "burglary" {
+		t.Errorf("spool head = %+v, want burglary", events)
+	}
+}
+
+func TestAsyncReporter_SpoolNeverDrops(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	spoolPath := filepath.Join(t.TempDir(), "spool.jsonl")
+	errorWriter := &countingWriter{}
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, errorWriter, withSpool(trustServer(t, ts), spoolPath))
+	if err != nil {
+		t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+	}
+
+	// Far more than the queue holds, faster than the disk takes them
+	const count = 2000
+	for i := 0; i < count; i++ {
+		reporter.Write([]byte(fmt.Sprintf("event %d", i)))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	reporter.Close(ctx)
+
+	s, err := OpenSpool(spoolPath, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer s.Close()
+	if events, _, _ := s.PeekBatch(count + 1); len(events) != count {
+		t.Errorf("spool holds %d events, want %d", len(events), count)
+	}
+}
+
+// countingWriter counts writes and is safe for concurrent use
+type countingWriter struct {
+	writes atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes.Add(1)
+	return len(p), nil
+}
+
+func TestAsyncReporter_SpoolReadErrorBacksOff(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	origBase := retryBaseDelay
+	retryBaseDelay = 100 * time.Millisecond
+	defer func() { retryBaseDelay = origBase }()
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	errorWriter := &countingWriter{}
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, errorWriter, withSpool(trustServer(t, ts), filepath.Join(t.TempDir(), "spool.jsonl")))
+	if err != nil {
+		t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+	}
+	defer reporter.Close(context.Background())
+
+	// Pending bytes that can no longer be read
+	reporter.spool.mu.Lock()
+	reporter.spool.file.Close()
+	reporter.spool.size += 100
+	reporter.spool.mu.Unlock()
+	reporter.spool.notify <- struct{}{}
+
+	time.Sleep(250 * time.Millisecond)
+	if got := errorWriter.writes.Load(); got == 0 || got > 5 {
+		t.Errorf("%d spool errors reported in 250ms, want a few with backoff", got)
+	}
+}
+
+func TestAsyncReporter_RetriesHonorRetryAfter(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	var calls atomic.Int64
+	var firstAt atomic.Int64
+	delivered := make(chan time.Time, 1)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			firstAt.Store(time.Now().UnixNano())
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		delivered <- time.Now()
+	}))
+	defer ts.Close()
+
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, trustServer(t, ts))
+	if err != nil {
+		t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+	}
+	reporter.Write([]byte("zone 3 faulted"))
+
+	select {
+	case at := <-delivered:
+		if wait := at.Sub(time.Unix(0, firstAt.Load())); wait < 900*time.Millisecond {
+			t.Errorf("retried after %v, want Retry-After of 1s honoured", wait)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for retry")
+	}
+
+	if lost, err := reporter.Close(context.Background()); err != nil || lost != 0 {
+		t.Errorf("Close() = %d, %v, want 0, nil", lost, err)
+	}
+}
+
+func TestAsyncReporter_PermanentFailureDeadLetters(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	var calls atomic.Int64
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad system_id"))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	deadLetterPath := filepath.Join(dir, "dead-letter.jsonl")
+	opts := trustServer(t, ts)
+	opts.SpoolPath = filepath.Join(dir, "spool.jsonl")
+	opts.DeadLetterPath = deadLetterPath
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, opts)
+	if err != nil {
+		t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+	}
+	reporter.Write([]byte("panic alarm"))
+
+	if _, err := reporter.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A 4xx is not retried and does not block the spool
+	if got := calls.Load(); got != 1 {
+		t.Errorf("API called %d times, want 1", got)
+	}
+
+	data, err := os.ReadFile(deadLetterPath)
+	if err != nil {
+		t.Fatalf("reading dead-letter file: %v", err)
+	}
+	var record deadLetterRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("dead-letter record %q: %v", data, err)
+	}
+	if record.StatusCode != http.StatusBadRequest || record.Response != "bad system_id" || record.Event.EventMessage != "panic alarm" {
+		t.Errorf("dead-letter record = %+v", record)
+	}
+}
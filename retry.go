@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultMaxAttempts is how many times an event is tried before it is dropped
+// when no spool is configured
+const defaultMaxAttempts = 5
+
+// Backoff bounds between delivery attempts, variables to allow shortening in tests
+var (
+	retryBaseDelay = 1 * time.Second
+	retryMaxDelay  = 60 * time.Second
+)
+
+// deliveryError describes why the API did not accept an event
+type deliveryError struct {
+	StatusCode int           // HTTP status, 0 for transport errors
+	Body       string        // Response body, if any
+	RetryAfter time.Duration // From the Retry-After header on 429/503, 0 if absent
+	Err        error         // Transport error, if any
+}
+
+func (e *deliveryError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("request error: %v", e.Err)
+	}
+	return fmt.Sprintf("API error: %d - %s", e.StatusCode, e.Body)
+}
+
+func (e *deliveryError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent reports whether retrying cannot help: any 4xx other than
+// 408 Request Timeout and 429 Too Many Requests
+func (e *deliveryError) Permanent() bool {
+	if e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests {
+		return false
+	}
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// isPermanent reports whether err should not be retried. Errors other than
+// deliveryError (e.g. marshalling, or an invalid URL) would fail the same way
+// again, so they are permanent too and dead-lettered like a 4xx.
+func isPermanent(err error) bool {
+	var de *deliveryError
+	if errors.As(err, &de) {
+		return de.Permanent()
+	}
+	return true
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// retryDelay returns how long to wait before the given retry attempt (1-based).
+// A server-provided Retry-After wins; otherwise the exponential delay is
+// jittered between half and the full value so reporters do not retry in lockstep.
+func retryDelay(attempt int, err error) time.Duration {
+	var de *deliveryError
+	if errors.As(err, &de) && de.RetryAfter > 0 {
+		if de.RetryAfter > retryMaxDelay {
+			return retryMaxDelay
+		}
+		return de.RetryAfter
+	}
+
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// deadLetterRecord is one line of the dead-letter file
+type deadLetterRecord struct {
+	FailedAt   string `json:"failed_at"`
+	StatusCode int    `json:"status_code"`     // 0 if the request was never answered
+	Response   string `json:"response"`        // Response body
+	Error      string `json:"error,omitempty"` // Why the event could not be sent, when there was no response
+	Event      Event  `json:"event"`
+}
+
+// deadLetterFile appends permanently rejected events as JSON lines so
+// operators can inspect and re-send them
+type deadLetterFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (d *deadLetterFile) write(event Event, cause error) error {
+	rec := deadLetterRecord{
+		FailedAt: time.Now().UTC().Format(time.RFC3339),
+		Event:    event,
+	}
+	var de *deliveryError
+	if errors.As(cause, &de) && de.StatusCode != 0 {
+		rec.StatusCode = de.StatusCode
+		rec.Response = de.Body
+	} else {
+		rec.Error = cause.Error()
+	}
+	record, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f, err := os.OpenFile(d.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(record, '\n'))
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "120", 2 * time.Minute},
+		{"zero", "0", 0},
+		{"negative", "-5", 0},
+		{"http date", now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{"date in past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"garbage", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryError_Permanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *deliveryError
+		want bool
+	}{
+		{"transport", &deliveryError{Err: errors.New("connection refused")}, false},
+		{"bad request", &deliveryError{StatusCode: 400}, true},
+		{"unauthorized", &deliveryError{StatusCode: 401}, true},
+		{"request timeout", &deliveryError{StatusCode: 408}, false},
+		{"too many requests", &deliveryError{StatusCode: 429}, false},
+		{"server error", &deliveryError{StatusCode: 500}, false},
+		{"unavailable", &deliveryError{StatusCode: 503}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Permanent(); got != tt.want {
+				t.Errorf("Permanent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	origBase, origMax := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = 100*time.Millisecond, time.Second
+	defer func() { retryBaseDelay, retryMaxDelay = origBase, origMax }()
+
+	transient := &deliveryError{StatusCode: 500}
+	for attempt := 1; attempt <= 6; attempt++ {
+		full := retryBaseDelay << (attempt - 1)
+		if full > retryMaxDelay {
+			full = retryMaxDelay
+		}
+		got := retryDelay(attempt, transient)
+		if got < full/2 || got > full {
+			t.Errorf("retryDelay(%d) = %v, want within [%v, %v]", attempt, got, full/2, full)
+		}
+	}
+
+	// Retry-After takes precedence but is capped
+	if got := retryDelay(1, &deliveryError{StatusCode: 429, RetryAfter: 300 * time.Millisecond}); got != 300*time.Millisecond {
+		t.Errorf("retryDelay with Retry-After = %v, want 300ms", got)
+	}
+	if got := retryDelay(1, &deliveryError{StatusCode: 503, RetryAfter: time.Hour}); got != retryMaxDelay {
+		t.Errorf("retryDelay with long Retry-After = %v, want %v", got, retryMaxDelay)
+	}
+}
+
+func TestDeadLetterFile_Write(t *testing.T) {
+	tests := []struct {
+		name  string
+		cause error
+		want  deadLetterRecord
+	}{
+		{
+			name:  "rejected by the API",
+			cause: &deliveryError{StatusCode: 422, Body: "invalid event"},
+			want:  deadLetterRecord{StatusCode: 422, Response: "invalid event"},
+		},
+		{
+			name:  "never sent",
+			cause: fmt.Errorf("failed to create request: %w", errors.New("invalid URL escape")),
+			want:  deadLetterRecord{Error: "failed to create request: invalid URL escape"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &deadLetterFile{path: filepath.Join(t.TempDir(), "dead-letter.jsonl")}
+			if err := d.write(Event{EventMessage: "panic alarm"}, tt.cause); err != nil {
+				t.Fatalf("write() error = %v", err)
+			}
+
+			data, err := os.ReadFile(d.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got deadLetterRecord
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("dead-letter record %q: %v", data, err)
+			}
+			if got.StatusCode != tt.want.StatusCode || got.Response != tt.want.Response || got.Error != tt.want.Error || got.Event.EventMessage != "panic alarm" {
+				t.Errorf("dead-letter record = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
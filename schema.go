@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+
+	"envisaMon/tpi"
+)
+
+// Event payload schema versions, selectable per reporter
+const (
+	SchemaV1 = 1 // Free-text event_message only
+	SchemaV2 = 2 // Adds schema_version, sequence, boot_id and fields decoded from TPI packets
+)
+
+// bootID identifies this run of EnvisaMon in v2 payloads. Sequence numbers
+// restart with each run, so consumers order events by boot_id and sequence.
+var bootID = newUUID()
+
+// Packet types reported in the v2 packet_type field
+const (
+	PacketKeypadUpdate         = "keypad_update"
+	PacketZoneStateChange      = "zone_state_change"
+	PacketPartitionStateChange = "partition_state_change"
+	PacketRealtimeCID          = "realtime_cid"
+	PacketZoneTimerDump        = "zone_timer_dump"
+	PacketCommandAck           = "command_ack"
+	PacketUnknown              = "unknown"
+)
+
+// CIDFields is the decoded Contact ID event in a v2 payload
+type CIDFields struct {
+	Code        int    `json:"code"`
+	Qualifier   string `json:"qualifier,omitempty"` // "event" or "restore", omitted if the panel sent another value
+	Category    string `json:"category"`
+	Description string `json:"description"`
+}
+
+// LEDFlags are the keypad LED/ICON flags in a v2 payload
+type LEDFlags struct {
+	Ready               bool `json:"ready"`
+	ArmedAway           bool `json:"armed_away"`
+	ArmedStay           bool `json:"armed_stay"`
+	ArmedZeroEntryDelay bool `json:"armed_zero_entry_delay"`
+	Alarm               bool `json:"alarm"`
+	AlarmInMemory       bool `json:"alarm_in_memory"`
+	AlarmFireZone       bool `json:"alarm_fire_zone"`
+	Fire                bool `json:"fire"`
+	Bypass              bool `json:"bypass"`
+	Chime               bool `json:"chime"`
+	Check               bool `json:"check"`
+	LowBattery          bool `json:"low_battery"`
+	ACPresent           bool `json:"ac_present"`
+}
+
+// decodeTPI fills the v2 fields of event from a raw TPI line. Lines that do
+// not parse are left with only the v1 fields.
+func decodeTPI(event *Event, line string) {
+	packet, err := tpi.Parse(line)
+	if err != nil {
+		return
+	}
+	event.CommandCode = packet.Envelope().Code
+	event.PacketType = packetTypeOf(packet)
+
+	switch p := packet.(type) {
+	case tpi.KeypadUpdate:
+		event.Partition = p.Partition
+		event.Zone = p.UserZone
+		event.KeypadText = p.Alpha
+		event.LEDs = &LEDFlags{
+			Ready:               p.LEDs.Ready,
+			ArmedAway:           p.LEDs.ArmedAway,
+			ArmedStay:           p.LEDs.ArmedStay,
+			ArmedZeroEntryDelay: p.LEDs.ArmedZeroEntryDelay,
+			Alarm:               p.LEDs.Alarm,
+			AlarmInMemory:       p.LEDs.AlarmInMemory,
+			AlarmFireZone:       p.LEDs.AlarmFireZone,
+			Fire:                p.LEDs.Fire,
+			Bypass:              p.LEDs.Bypass,
+			Chime:               p.LEDs.Chime,
+			Check:               p.LEDs.Check,
+			LowBattery:          p.LEDs.LowBattery,
+			ACPresent:           p.LEDs.ACPresent,
+		}
+	case tpi.ZoneStateChange:
+		event.OpenZones = p.Open
+	case tpi.PartitionStateChange:
+		for _, state := range p.States {
+			event.PartitionStates = append(event.PartitionStates, state.String())
+		}
+	case tpi.RealtimeCID:
+		event.Partition = p.Partition
+		if p.IsUser {
+			event.User = p.ZoneOrUser
+		} else {
+			event.Zone = p.ZoneOrUser
+		}
+		event.CID = &CIDFields{
+			Code:        p.Code,
+			Category:    string(p.Category),
+			Description: p.Description,
+		}
+		if p.Qualifier == tpi.CIDEvent || p.Qualifier == tpi.CIDRestore {
+			event.CID.Qualifier = p.Qualifier.String()
+		}
+	}
+}
+
+// packetTypeOf returns the packet_type name of a parsed packet
+func packetTypeOf(packet tpi.Packet) string {
+	switch packet.(type) {
+	case tpi.KeypadUpdate:
+		return PacketKeypadUpdate
+	case tpi.ZoneStateChange:
+		return PacketZoneStateChange
+	case tpi.PartitionStateChange:
+		return PacketPartitionStateChange
+	case tpi.RealtimeCID:
+		return PacketRealtimeCID
+	case tpi.ZoneTimerDump:
+		return PacketZoneTimerDump
+	case tpi.CommandAck:
+		return PacketCommandAck
+	}
+	return PacketUnknown
+}
+
+// packetType returns the packet_type name of a raw TPI line, or "" if it is not a packet
+func packetType(line string) string {
+	packet, err := tpi.Parse(line)
+	if err != nil {
+		return ""
+	}
+	return packetTypeOf(packet)
+}
+
+// validSchema reports whether v is a supported schema version
+func validSchema(v int) error {
+	if v != SchemaV1 && v != SchemaV2 {
+		return fmt.Errorf("unknown event schema version %d", v)
+	}
+	return nil
+}
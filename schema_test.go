@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeTPI(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Event
+	}{
+		{
+			name: "keypad update",
+			line: "%00,01,1C08,08,00,****DISARMED****  Ready to Arm  $",
+			want: Event{
+				PacketType:  PacketKeypadUpdate,
+				CommandCode: "00",
+				Partition:   1,
+				Zone:        8,
+				KeypadText:  "****DISARMED****  Ready to Arm  ",
+				LEDs:        &LEDFlags{Ready: true, ACPresent: true},
+			},
+		},
+		{
+			name: "zone state change",
+			line: "%01,0100000000000080$",
+			want: Event{PacketType: PacketZoneStateChange, CommandCode: "01", OpenZones: []int{1, 64}},
+		},
+		{
+			name: "partition state change",
+			line: "%02,0100000000000000$",
+			want: Event{
+				PacketType:      PacketPartitionStateChange,
+				CommandCode:     "02",
+				PartitionStates: []string{"Ready", "Not Used", "Not Used", "Not Used", "Not Used", "Not Used", "Not Used", "Not Used"},
+			},
+		},
+		{
+			name: "CID by user",
+			line: "%03,3441010020$",
+			want: Event{
+				PacketType:  PacketRealtimeCID,
+				CommandCode: "03",
+				Partition:   1,
+				User:        2,
+				CID:         &CIDFields{Code: 441, Qualifier: "restore", Category: "Open/Close", Description: "Armed Stay"},
+			},
+		},
+		{
+			name: "CID with unknown qualifier",
+			line: "%03,5130010050$",
+			want: Event{
+				PacketType:  PacketRealtimeCID,
+				CommandCode: "03",
+				Partition:   1,
+				Zone:        5,
+				CID:         &CIDFields{Code: 130, Category: "Burglar", Description: "Burglary"},
+			},
+		},
+		{
+			name: "command ack",
+			line: "^03,00$",
+			want: Event{PacketType: PacketCommandAck, CommandCode: "03"},
+		},
+		{
+			name: "unknown packet",
+			line: "%AB,1234$",
+			want: Event{PacketType: PacketUnknown, CommandCode: "AB"},
+		},
+		{
+			name: "not a packet",
+			line: "Login:",
+			want: Event{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Event
+			decodeTPI(&got, tt.line)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeTPI(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAsyncReporter_SchemaV2(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	payloads := make(chan map[string]any, 10)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+		payloads <- payload
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		schema int
+		want   map[string]any // Fields expected in the second payload; nil means absent
+	}{
+		{"v1 omits decoded fields", SchemaV1, map[string]any{"schema_version": nil, "sequence": nil, "boot_id": nil, "packet_type": nil}},
+		{"v2 adds decoded fields", SchemaV2, map[string]any{"schema_version": 2.0, "sequence": 2.0, "boot_id": bootID, "packet_type": PacketRealtimeCID, "command_code": "03"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := trustServer(t, ts)
+			opts.SpoolPath = t.TempDir() + "/spool.jsonl" // One sender keeps payloads in order
+			opts.Schema = tt.schema
+			reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, opts)
+			if err != nil {
+				t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+			}
+			defer reporter.Close(context.Background())
+			reporter.Write([]byte("%02,0100000000000000$"))
+			reporter.Write([]byte("%03,3441010020$"))
+
+			var payload map[string]any
+			for i := 0; i < 2; i++ {
+				select {
+				case payload = <-payloads:
+				case <-time.After(5 * time.Second):
+					t.Fatal("Timed out waiting for report")
+				}
+			}
+			if payload["event_message"] != "%03,3441010020$" {
+				t.Fatalf("event_message = %v, want the CID line", payload["event_message"])
+			}
+			for field, want := range tt.want {
+				got, present := payload[field]
+				if want == nil && present {
+					t.Errorf("%s = %v, want absent", field, got)
+				} else if want != nil && got != want {
+					t.Errorf("%s = %v, want %v", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewAsyncReporter_UnknownSchema(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	if _, err := NewAsyncReporterWithOptions("https://example.com", "test-system", "TPI", false, io.Discard, ReporterOptions{Schema: 3}); err == nil {
+		t.Error("NewAsyncReporterWithOptions() with schema 3, want error")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		timestamp string
+		body      string
+		want      string
+	}{
+		// printf '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+		{"reference", "secret", "1700000000", `{"a":1}`, "sha256=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signature([]byte(tt.key), tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("signature() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	base := signature([]byte("secret"), "1700000000", []byte(`{"a":1}`))
+	if signature([]byte("secret"), "1700000001", []byte(`{"a":1}`)) == base {
+		t.Error("signature() does not cover the timestamp")
+	}
+	if signature([]byte("secret"), "1700000000", []byte(`{"a":2}`)) == base {
+		t.Error("signature() does not cover the body")
+	}
+}
+
+func TestAsyncReporter_SignsRequests(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	key := []byte("shared-secret")
+	type signed struct {
+		timestamp, signature string
+		body                 []byte
+	}
+	received := make(chan signed, 1)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- signed{r.Header.Get(signatureTimestampHeader), r.Header.Get(signatureHeader), body}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	opts := trustServer(t, ts)
+	opts.SigningKey = key
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, opts)
+	if err != nil {
+		t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+	}
+	defer reporter.Close(context.Background())
+	reporter.Write([]byte("%02,0100000000000000$"))
+
+	select {
+	case got := <-received:
+		sent, err := strconv.ParseInt(got.timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+			t.Errorf("%s = %q, want current Unix time", signatureTimestampHeader, got.timestamp)
+		}
+		if want := signature(key, got.timestamp, got.body); !hmac.Equal([]byte(got.signature), []byte(want)) {
+			t.Errorf("%s = %q, want %q", signatureHeader, got.signature, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for report")
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultSpoolMaxBytes caps the spool so an unreachable API cannot fill the disk
+const defaultSpoolMaxBytes = 50 * 1024 * 1024
+
+// spoolCompactBytes is how large the delivered prefix may grow before Ack
+// rewrites the file without it. A variable to allow tuning in tests.
+var spoolCompactBytes int64 = 1024 * 1024
+
+// Spool is a disk-backed, append-only queue of events awaiting delivery.
+// Events are stored one JSON object per line; a sidecar ".offset" file records
+// where the first unacknowledged event starts so the queue survives restarts.
+type Spool struct {
+	mu         sync.Mutex
+	path       string
+	offsetPath string
+	file       *os.File
+	offset     int64 // Start of the first unacknowledged event
+	size       int64 // End of the last complete event
+	maxBytes   int64
+	dirty      bool          // Appended since the last Sync
+	notify     chan struct{} // Signalled (non-blocking) after each Append
+}
+
+// OpenSpool opens or creates the spool at path, resuming from the last
+// acknowledged position. A record left half-written by a crash is discarded.
+func OpenSpool(path string, maxBytes int64) (*Spool, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultSpoolMaxBytes
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+
+	s := &Spool{
+		path:       path,
+		offsetPath: path + ".offset",
+		file:       file,
+		maxBytes:   maxBytes,
+		notify:     make(chan struct{}, 1),
+	}
+
+	if err := s.recover(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// recover loads the acknowledged offset and trims any torn trailing record
+func (s *Spool) recover() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat spool: %w", err)
+	}
+	s.size = info.Size()
+
+	// Drop a partial last line so the next append starts on a record boundary
+	if s.size > 0 {
+		tail := make([]byte, 1)
+		if _, err := s.file.ReadAt(tail, s.size-1); err != nil {
+			return fmt.Errorf("failed to read spool: %w", err)
+		}
+		if tail[0] != '\n' {
+			end, err := s.lastRecordEnd()
+			if err != nil {
+				return err
+			}
+			if err := s.file.Truncate(end); err != nil {
+				return fmt.Errorf("failed to trim spool: %w", err)
+			}
+			s.size = end
+		}
+	}
+
+	data, err := os.ReadFile(s.offsetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read spool offset: %w", err)
+	}
+	if len(data) > 0 {
+		offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil || offset < 0 {
+			return fmt.Errorf("invalid spool offset %q", strings.TrimSpace(string(data)))
+		}
+		s.offset = offset
+	}
+	if s.offset > s.size {
+		s.offset = s.size
+	}
+	return s.compactIfDrained()
+}
+
+// lastRecordEnd returns the offset just after the final newline in the file
+func (s *Spool) lastRecordEnd() (int64, error) {
+	reader := bufio.NewReader(io.NewSectionReader(s.file, 0, s.size))
+	var end, pos int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		pos += int64(len(line))
+		if err == io.EOF {
+			return end, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan spool: %w", err)
+		}
+		end = pos
+	}
+}
+
+// Append adds an event to the end of the spool. It is not durable until Sync,
+// so callers can append several events per fsync.
+func (s *Spool) Append(event Event) error {
+	record, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	record = append(record, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size-s.offset+int64(len(record)) > s.maxBytes {
+		return fmt.Errorf("spool full (%d bytes pending)", s.size-s.offset)
+	}
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		return fmt.Errorf("failed to write spool: %w", err)
+	}
+	s.size += int64(len(record))
+	s.dirty = true
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// PeekBatch returns up to max of the oldest unacknowledged events and the
+// offset to pass to Ack once they have been delivered. It returns no events
+// when the spool is empty. The batch stops short of an undecodable record; if
+// the first record is undecodable it is returned as an error together with
+// the offset that skips it.
+func (s *Spool) PeekBatch(max int) (events []Event, next int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next = s.offset
+	reader := bufio.NewReader(io.NewSectionReader(s.file, s.offset, s.size-s.offset))
+	for len(events) < max && next < s.size {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if len(events) > 0 {
+				break
+			}
+			return nil, s.offset, fmt.Errorf("failed to read spool: %w", err)
+		}
+
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			if len(events) > 0 {
+				break
+			}
+			return nil, next + int64(len(line)), fmt.Errorf("corrupt spool record at offset %d: %w", next, err)
+		}
+		events = append(events, event)
+		next += int64(len(line))
+	}
+	return events, next, nil
+}
+
+// Sync flushes appended events to disk
+func (s *Spool) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// Ack marks everything before next as delivered
+func (s *Spool) Ack(next int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if next <= s.offset || next > s.size {
+		return nil
+	}
+	s.offset = next
+	if err := s.compactIfDrained(); err != nil {
+		return err
+	}
+	if s.offset >= spoolCompactBytes && s.offset >= s.size-s.offset {
+		// Copying at most as much as is dropped keeps compaction amortised
+		return s.compact()
+	}
+	return s.writeOffset()
+}
+
+// acked returns the offset of the first unacknowledged event
+func (s *Spool) acked() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset
+}
+
+// Pending returns the number of bytes awaiting delivery
+func (s *Spool) Pending() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size - s.offset
+}
+
+// Notify returns a channel that receives a value after each Append
+func (s *Spool) Notify() <-chan struct{} {
+	return s.notify
+}
+
+// Close closes the spool file, leaving undelivered events for the next run
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// compactIfDrained truncates the file once every event has been delivered
+func (s *Spool) compactIfDrained() error {
+	if s.offset < s.size || s.size == 0 {
+		return nil
+	}
+	if err := s.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate spool: %w", err)
+	}
+	s.offset, s.size = 0, 0
+	return s.writeOffset()
+}
+
+// compact rewrites the spool without the delivered prefix. A crash part way
+// through replays that prefix on the next start rather than losing events.
+func (s *Spool) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to compact spool: %w", err)
+	}
+	pending := s.size - s.offset
+	if _, err := io.Copy(tmp, io.NewSectionReader(s.file, s.offset, pending)); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact spool: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact spool: %w", err)
+	}
+
+	// Reset the offset before swapping files, so a crash in between leaves
+	// the old file to be replayed from the start
+	acked := s.offset
+	s.offset = 0
+	if err := s.writeOffset(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		s.offset = acked
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		s.offset = acked
+		s.writeOffset()
+		return fmt.Errorf("failed to compact spool: %w", err)
+	}
+	s.file.Close()
+	s.file = tmp
+	s.size = pending
+	s.dirty = false
+	return nil
+}
+
+// writeOffset atomically persists the acknowledged offset
+func (s *Spool) writeOffset() error {
+	tmp := s.offsetPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(s.offset, 10)), 0644); err != nil {
+		return fmt.Errorf("failed to write spool offset: %w", err)
+	}
+	if err := os.Rename(tmp, s.offsetPath); err != nil {
+		return fmt.Errorf("failed to write spool offset: %w", err)
+	}
+	return nil
+}
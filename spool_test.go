@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSpool_AppendPeekAck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	s, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer s.Close()
+
+	if events, _, err := s.PeekBatch(1); len(events) != 0 || err != nil {
+		t.Fatalf("PeekBatch() on empty spool = %v, err %v", events, err)
+	}
+
+	for _, msg := range []string{"first", "second"} {
+		if err := s.Append(Event{EventID: msg, EventMessage: msg}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	for _, want := range []string{"first", "second"} {
+		events, next, err := s.PeekBatch(1)
+		if err != nil || len(events) != 1 {
+			t.Fatalf("PeekBatch() = %v, err %v", events, err)
+		}
+		if events[0].EventMessage != want {
+			t.Errorf("PeekBatch() = %q, want %q", events[0].EventMessage, want)
+		}
+
+		// Peeking again without acking returns the same event
+		if again, _, _ := s.PeekBatch(1); len(again) != 1 || again[0].EventMessage != want {
+			t.Errorf("second PeekBatch() = %v, want %q", again, want)
+		}
+		if err := s.Ack(next); err != nil {
+			t.Fatalf("Ack() error = %v", err)
+		}
+	}
+
+	// Fully drained spools are truncated
+	if info, _ := os.Stat(path); info.Size() != 0 {
+		t.Errorf("spool size = %d after draining, want 0", info.Size())
+	}
+	if s.Pending() != 0 {
+		t.Errorf("Pending() = %d, want 0", s.Pending())
+	}
+}
+
+func TestSpool_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+
+	s, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	for _, msg := range []string{"one", "two", "three"} {
+		s.Append(Event{EventMessage: msg})
+	}
+	_, next, _ := s.PeekBatch(1)
+	s.Ack(next)
+	s.Close()
+
+	// Simulate a crash halfway through writing a fourth record
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"event_id":"torn`)
+	f.Close()
+
+	s, err = OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("reopen OpenSpool() error = %v", err)
+	}
+	defer s.Close()
+
+	var got []string
+	for {
+		events, next, err := s.PeekBatch(1)
+		if err != nil {
+			t.Fatalf("PeekBatch() error = %v", err)
+		}
+		if len(events) == 0 {
+			break
+		}
+		got = append(got, events[0].EventMessage)
+		s.Ack(next)
+	}
+
+	if len(got) != 2 || got[0] != "two" || got[1] != "three" {
+		t.Errorf("replayed %v, want [two three]", got)
+	}
+}
+
+func TestSpool_MaxBytes(t *testing.T) {
+	s, err := OpenSpool(filepath.Join(t.TempDir(), "spool.jsonl"), 100)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Append(Event{EventMessage: "small"}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if err := s.Append(Event{EventMessage: "this one no longer fits in the spool"}); err == nil {
+		t.Error("Append() beyond max bytes should fail")
+	}
+}
+
+func TestSpool_SkipsCorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	os.WriteFile(path, []byte("not json\n{\"event_message\":\"ok\"}\n"), 0644)
+
+	s, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer s.Close()
+
+	events, next, err := s.PeekBatch(1)
+	if err == nil || len(events) != 0 {
+		t.Fatalf("PeekBatch() on corrupt record = %v, err %v, want error", events, err)
+	}
+	s.Ack(next)
+
+	events, _, err = s.PeekBatch(1)
+	if err != nil || len(events) != 1 || events[0].EventMessage != "ok" {
+		t.Errorf("PeekBatch() after skip = %+v, err %v", events, err)
+	}
+}
+
+func TestSpool_PeekBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	os.WriteFile(path, []byte("{\"event_message\":\"a\"}\n{\"event_message\":\"b\"}\nnot json\n{\"event_message\":\"c\"}\n"), 0644)
+
+	s, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+	defer s.Close()
+
+	// The batch stops short of the corrupt record
+	events, next, err := s.PeekBatch(10)
+	if err != nil || len(events) != 2 || events[0].EventMessage != "a" || events[1].EventMessage != "b" {
+		t.Fatalf("PeekBatch() = %+v, err %v, want a and b", events, err)
+	}
+	s.Ack(next)
+
+	if _, next, err = s.PeekBatch(10); err == nil {
+		t.Fatal("PeekBatch() at corrupt record, want error")
+	}
+	s.Ack(next)
+
+	events, next, err = s.PeekBatch(10)
+	if err != nil || len(events) != 1 || events[0].EventMessage != "c" {
+		t.Fatalf("PeekBatch() after skip = %+v, err %v, want c", events, err)
+	}
+	s.Ack(next)
+
+	if events, _, err := s.PeekBatch(10); err != nil || len(events) != 0 {
+		t.Errorf("PeekBatch() on drained spool = %+v, err %v", events, err)
+	}
+}
+
+func TestSpool_CompactsDeliveredPrefix(t *testing.T) {
+	original := spoolCompactBytes
+	defer func() { spoolCompactBytes = original }()
+	spoolCompactBytes = 100
+
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	s, err := OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("OpenSpool() error = %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		msg := fmt.Sprintf("event-%d", i)
+		if err := s.Append(Event{EventID: msg, EventMessage: msg}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	before, _ := os.Stat(path)
+
+	// Deliver 6 of 10, which passes the threshold and is more than what is left
+	_, next, err := s.PeekBatch(6)
+	if err != nil {
+		t.Fatalf("PeekBatch() error = %v", err)
+	}
+	if err := s.Ack(next); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+
+	after, _ := os.Stat(path)
+	if after.Size() != s.Pending() || after.Size() >= before.Size() {
+		t.Errorf("spool size = %d after compaction (was %d), want Pending() = %d", after.Size(), before.Size(), s.Pending())
+	}
+
+	// Appends and reopening continue from the compacted file
+	if err := s.Append(Event{EventID: "event-10", EventMessage: "event-10"}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	s.Close()
+	s, err = OpenSpool(path, 0)
+	if err != nil {
+		t.Fatalf("reopen OpenSpool() error = %v", err)
+	}
+	defer s.Close()
+
+	events, _, err := s.PeekBatch(100)
+	if err != nil {
+		t.Fatalf("PeekBatch() after reopen error = %v", err)
+	}
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.EventMessage)
+	}
+	want := []string{"event-6", "event-7", "event-8", "event-9", "event-10"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events after compaction = %v, want %v", got, want)
+	}
+}
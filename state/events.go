@@ -0,0 +1,114 @@
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"envisaMon/tpi"
+)
+
+// DefaultEventHistory is how many events a Store keeps for Events
+const DefaultEventHistory = 1000
+
+// Event types
+const (
+	EventZoneFaulted    = "zone_faulted"
+	EventZoneRestored   = "zone_restored"
+	EventPartitionState = "partition_state"
+	EventCID            = "cid"
+)
+
+// Event is one notable change, in the order the Store saw it
+type Event struct {
+	Sequence    uint64    `json:"sequence"` // Increases by one per event, starting at 1
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	Partition   int       `json:"partition,omitempty"`
+	Zone        int       `json:"zone,omitempty"`
+	User        int       `json:"user,omitempty"`
+	State       string    `json:"state,omitempty"` // New partition state, or the CID qualifier
+	Description string    `json:"description,omitempty"`
+}
+
+// record appends an event, dropping the oldest once the history is full.
+// Callers hold s.mu.
+func (s *Store) record(e Event) {
+	s.eventSeq++
+	e.Sequence = s.eventSeq
+	if len(s.events) < s.eventLimit {
+		s.events = append(s.events, e)
+		return
+	}
+	if s.eventLimit == 0 {
+		return
+	}
+	copy(s.events, s.events[1:])
+	s.events[len(s.events)-1] = e
+}
+
+// recordZone records a zone opening or closing
+func (s *Store) recordZone(n int, open bool, at time.Time) {
+	e := Event{Time: at, Type: EventZoneRestored, Zone: n}
+	if open {
+		e.Type = EventZoneFaulted
+	}
+	s.record(e)
+}
+
+// recordCID records a Contact ID event
+func (s *Store) recordCID(c tpi.RealtimeCID, at time.Time) {
+	e := Event{
+		Time:        at,
+		Type:        EventCID,
+		Partition:   c.Partition,
+		State:       c.Qualifier.String(),
+		Description: fmt.Sprintf("%03d %s", c.Code, c.Description),
+	}
+	if c.IsUser {
+		e.User = c.ZoneOrUser
+	} else {
+		e.Zone = c.ZoneOrUser
+	}
+	s.record(e)
+}
+
+// SetEventHistory changes how many events are kept, discarding the oldest if
+// there are already more
+func (s *Store) SetEventHistory(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	s.eventLimit = n
+	if len(s.events) > n {
+		s.events = append([]Event(nil), s.events[len(s.events)-n:]...)
+	}
+}
+
+// Events returns the retained events with a sequence number after since, oldest
+// first. Pass 0 for the whole history.
+func (s *Store) Events(since uint64) []Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []Event{}
+	for _, e := range s.events {
+		if e.Sequence > since {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// EventsSince returns the retained events that happened after t, oldest first
+func (s *Store) EventsSince(t time.Time) []Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []Event{}
+	for _, e := range s.events {
+		if e.Time.After(t) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore_Events(t *testing.T) {
+	s := New()
+	s.Apply(message("%02,0100000000000000$", 0))             // Partition 1 ready
+	s.Apply(message("%01,0400000000000000$", time.Second))   // Zone 3 faulted
+	s.Apply(message("%01,0400000000000000$", 2*time.Second)) // Repeat, no event
+	s.Apply(message("%03,3441010030$", 3*time.Second))       // CID close by user 3
+	s.Apply(message("%01,0000000000000000$", 4*time.Second)) // Zone 3 restored
+	s.Apply(message("%02,0500000000000000$", 5*time.Second)) // Partition 1 armed away
+
+	want := []Event{
+		{Sequence: 1, Time: t0, Type: EventPartitionState, Partition: 1, State: "Ready"},
+		{Sequence: 2, Time: t0.Add(time.Second), Type: EventZoneFaulted, Zone: 3},
+		{Sequence: 3, Time: t0.Add(3 * time.Second), Type: EventCID, Partition: 1, User: 3, State: "restore", Description: "441 Armed Stay"},
+		{Sequence: 4, Time: t0.Add(4 * time.Second), Type: EventZoneRestored, Zone: 3},
+		{Sequence: 5, Time: t0.Add(5 * time.Second), Type: EventPartitionState, Partition: 1, State: "Armed Away"},
+	}
+
+	tests := []struct {
+		name string
+		got  []Event
+		want []Event
+	}{
+		{name: "all", got: s.Events(0), want: want},
+		{name: "after sequence", got: s.Events(3), want: want[3:]},
+		{name: "after last sequence", got: s.Events(5), want: []Event{}},
+		{name: "after time", got: s.EventsSince(t0.Add(time.Second)), want: want[2:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.got) != len(tt.want) {
+				t.Fatalf("got %d events %+v, want %d", len(tt.got), tt.got, len(tt.want))
+			}
+			for i := range tt.want {
+				if tt.got[i] != tt.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, tt.got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStore_EventHistoryLimit(t *testing.T) {
+	s := New()
+	s.SetEventHistory(2)
+	for i := 0; i < 5; i++ {
+		open := "%01,0100000000000000$"
+		if i%2 == 1 {
+			open = "%01,0000000000000000$"
+		}
+		s.Apply(message(open, time.Duration(i)*time.Second))
+	}
+
+	got := s.Events(0)
+	if len(got) != 2 || got[0].Sequence != 4 || got[1].Sequence != 5 {
+		t.Errorf("Events(0) = %+v, want sequences 4 and 5", got)
+	}
+}
@@ -0,0 +1,140 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// LoadSnapshot restores state written by SaveSnapshot. Everything restored is
+// marked stale until the panel confirms it with a fresh %00, %01 or %02. Event
+// history is not restored, but new events continue its sequence numbers.
+func (s *Store) LoadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("invalid state snapshot %s: %w", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.partitions = make(map[int]*Partition, len(snap.Partitions))
+	for _, p := range snap.Partitions {
+		p.Stale = true
+		s.partitions[p.Number] = &p
+	}
+	s.zones = make(map[int]*Zone, len(snap.Zones))
+	for _, z := range snap.Zones {
+		z.Stale = true
+		s.zones[z.Number] = &z
+	}
+	s.troubles = snap.Troubles
+	s.troubles.Stale = true
+	s.keypadText = snap.KeypadText
+	s.updated = snap.Updated
+	if snap.EventSequence > s.eventSeq {
+		s.eventSeq = snap.EventSequence
+	}
+	return nil
+}
+
+// SaveSnapshot atomically writes the current state to path as JSON
+func (s *Store) SaveSnapshot(path string) error {
+	// Hold saveMu across taking the snapshot so concurrent saves land in order
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(s.Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// PersistTo saves a snapshot to path after changes, on a background goroutine
+// so Apply never waits for the disk. Changes made while a save is running are
+// coalesced into the next one. Failures are logged to logger and retried on the
+// next change. Call Close to stop saving and write a final snapshot.
+func (s *Store) PersistTo(path string, logger *log.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshotPath = path
+	s.logger = logger
+	if s.saveNeeded == nil {
+		s.saveNeeded = make(chan struct{}, 1)
+		s.saverDone = make(chan struct{})
+		go s.saver(s.saveNeeded, s.saverDone)
+	}
+}
+
+// Close stops the saver started by PersistTo after writing any pending change
+func (s *Store) Close() {
+	s.mu.Lock()
+	saveNeeded, saverDone := s.saveNeeded, s.saverDone
+	s.saveNeeded = nil
+	s.mu.Unlock()
+
+	if saveNeeded == nil {
+		return
+	}
+	close(saveNeeded)
+	<-saverDone
+}
+
+// saver writes a snapshot for each signal until the channel is closed
+func (s *Store) saver(saveNeeded <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for range saveNeeded {
+		s.save()
+	}
+}
+
+// persist asks the saver to write a snapshot, if PersistTo was called
+func (s *Store) persist() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.saveNeeded == nil {
+		return
+	}
+	select {
+	case s.saveNeeded <- struct{}{}:
+	default: // A save is already pending and will include this change
+	}
+}
+
+// save writes the snapshot to the path given to PersistTo
+func (s *Store) save() {
+	s.mu.RLock()
+	path, logger := s.snapshotPath, s.logger
+	s.mu.RUnlock()
+
+	if err := s.SaveSnapshot(path); err != nil && logger != nil {
+		logger.Printf("ERROR: Failed to save state snapshot: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// restoredStore saves a populated store and loads it into a new one
+func restoredStore(t *testing.T) *Store {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	s := New()
+	s.Apply(message("%00,01,8000,00,05,ARMED STAY$", 0))
+	s.Apply(message("%02,0400000000000000$", time.Second))
+	s.Apply(message("%01,0200000000000000$", 2*time.Second))
+	if err := s.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+
+	restored := New()
+	if err := restored.LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+	return restored
+}
+
+func TestStore_LoadSnapshotMarksStale(t *testing.T) {
+	s := restoredStore(t)
+
+	p, ok := s.Partition(1)
+	if !ok || !p.Stale || p.ArmedMode != ArmedStay || p.State != "Armed Stay" {
+		t.Errorf("restored Partition(1) = %+v, want stale armed stay", p)
+	}
+	z, ok := s.Zone(2)
+	if !ok || !z.Stale || !z.Open || !z.LastFaulted.Equal(t0.Add(2*time.Second)) {
+		t.Errorf("restored Zone(2) = %+v, want stale open zone", z)
+	}
+	snap := s.Snapshot()
+	if !snap.Troubles.Stale || !snap.Troubles.ACLoss {
+		t.Errorf("restored Troubles = %+v, want stale AC loss", snap.Troubles)
+	}
+	if snap.KeypadText != "ARMED STAY" || !snap.Updated.Equal(t0.Add(2*time.Second)) {
+		t.Errorf("restored snapshot = %+v", snap)
+	}
+}
+
+func TestStore_LoadSnapshotContinuesEventSequence(t *testing.T) {
+	s := restoredStore(t)
+	if got := s.Events(0); len(got) != 0 {
+		t.Errorf("restored Events(0) = %+v, want no history", got)
+	}
+
+	// The snapshot recorded a partition state and a zone fault
+	s.Apply(message("%01,0000000000000000$", time.Hour))
+	got := s.Events(0)
+	if len(got) != 1 || got[0].Sequence != 3 {
+		t.Errorf("Events(0) after restore = %+v, want one event with sequence 3", got)
+	}
+}
+
+func TestStore_FreshPacketsClearStale(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		check func(t *testing.T, s *Store)
+	}{
+		{
+			name: "partition state confirms partitions",
+			line: "%02,0400000000000000$",
+			check: func(t *testing.T, s *Store) {
+				if p, _ := s.Partition(1); p.Stale {
+					t.Errorf("Partition(1) = %+v, want fresh", p)
+				}
+				if z, _ := s.Zone(2); !z.Stale {
+					t.Errorf("Zone(2) = %+v, want still stale", z)
+				}
+			},
+		},
+		{
+			name: "keypad update confirms its partition and troubles",
+			line: "%00,01,8008,00,05,ARMED STAY$",
+			check: func(t *testing.T, s *Store) {
+				if p, _ := s.Partition(1); p.Stale {
+					t.Errorf("Partition(1) = %+v, want fresh", p)
+				}
+				if tr := s.Snapshot().Troubles; tr.Stale || tr.ACLoss {
+					t.Errorf("Troubles = %+v, want fresh with AC restored", tr)
+				}
+			},
+		},
+		{
+			name: "keypad update drops the restored partition state",
+			line: "%00,01,1C08,08,00,READY$",
+			check: func(t *testing.T, s *Store) {
+				p, _ := s.Partition(1)
+				if p.Stale || p.State != "" || p.ExitDelay || !p.Ready || p.ArmedMode != ArmedNone {
+					t.Errorf("Partition(1) = %+v, want fresh and ready with no state name until a %%02", p)
+				}
+			},
+		},
+		{
+			name: "unchanged zone state confirms zones",
+			line: "%01,0200000000000000$",
+			check: func(t *testing.T, s *Store) {
+				z, _ := s.Zone(2)
+				if z.Stale || !z.Open || !z.LastChange.Equal(t0.Add(2*time.Second)) {
+					t.Errorf("Zone(2) = %+v, want fresh, open, last change kept", z)
+				}
+			},
+		},
+		{
+			name: "zone state change closes restored zone",
+			line: "%01,0000000000000000$",
+			check: func(t *testing.T, s *Store) {
+				z, _ := s.Zone(2)
+				if z.Stale || z.Open || !z.LastChange.Equal(t0.Add(time.Hour)) {
+					t.Errorf("Zone(2) = %+v, want fresh and closed", z)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := restoredStore(t)
+			if !s.Apply(message(tt.line, time.Hour)) {
+				t.Error("Apply() = false, want confirming a stale value to be a change")
+			}
+			tt.check(t, s)
+		})
+	}
+}
+
+func TestStore_PersistTo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	var logs bytes.Buffer
+
+	s := New()
+	s.PersistTo(path, log.New(&logs, "", 0))
+	s.Apply(message("%02,0500000000000000$", 0))
+	s.Close() // Waits for the background save
+
+	restored := New()
+	if err := restored.LoadSnapshot(path); err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+	if p, _ := restored.Partition(1); p.ArmedMode != ArmedAway {
+		t.Errorf("persisted Partition(1) = %+v, want armed away", p)
+	}
+
+	// Save failures are logged, not fatal
+	s.PersistTo(filepath.Join(t.TempDir(), "missing", "state.json"), log.New(&logs, "", 0))
+	s.Apply(message("%02,0100000000000000$", time.Second))
+	s.Close()
+	if !strings.Contains(logs.String(), "ERROR: Failed to save state snapshot") {
+		t.Errorf("log = %q, want save error", logs.String())
+	}
+}
+
+func TestStore_LoadSnapshotErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := New().LoadSnapshot(filepath.Join(dir, "none.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadSnapshot() of missing file error = %v, want fs.ErrNotExist", err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte("{"), 0644)
+	if err := New().LoadSnapshot(bad); err == nil || !strings.Contains(err.Error(), "invalid state snapshot") {
+		t.Errorf("LoadSnapshot() of bad file error = %v", err)
+	}
+}
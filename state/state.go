@@ -0,0 +1,430 @@
+package state
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"envisaMon/tpi"
+)
+
+// Armed modes reported in Partition.ArmedMode
+const (
+	ArmedNone    = ""
+	ArmedAway    = "away"
+	ArmedStay    = "stay"
+	ArmedInstant = "instant" // Stay with zero entry delay
+	ArmedMaximum = "max"     // Away with zero entry delay
+)
+
+// Partition is the current state of one partition
+type Partition struct {
+	Number      int       `json:"number"`
+	State       string    `json:"state"` // Name of the last %02 state, e.g. "Ready", "Armed Away"
+	ArmedMode   string    `json:"armed_mode"`
+	Ready       bool      `json:"ready"`
+	ExitDelay   bool      `json:"exit_delay"`
+	Alarm       bool      `json:"alarm"`
+	AlarmMemory bool      `json:"alarm_memory"`
+	Bypass      bool      `json:"bypass"`
+	Chime       bool      `json:"chime"`
+	KeypadText  string    `json:"keypad_text"` // Latest text, not counted as a change
+	LastChange  time.Time `json:"last_change"`
+	Stale       bool      `json:"stale"` // Restored from a snapshot, not yet confirmed by the panel
+}
+
+// Zone is the current state of one zone
+type Zone struct {
+	Number      int       `json:"number"`
+	Open        bool      `json:"open"`
+	LastChange  time.Time `json:"last_change"`  // When Open last changed
+	LastFaulted time.Time `json:"last_faulted"` // When the zone last opened, zero if unknown
+	Stale       bool      `json:"stale"`        // Restored from a snapshot, not yet confirmed by the panel
+}
+
+// Troubles are system-wide trouble conditions from the keypad LEDs
+type Troubles struct {
+	ACLoss      bool `json:"ac_loss"`
+	LowBattery  bool `json:"low_battery"`
+	SystemCheck bool `json:"system_check"`
+	Fire        bool `json:"fire"`
+	Stale       bool `json:"stale"` // Restored from a snapshot, not yet confirmed by the panel
+}
+
+// Snapshot is a consistent copy of the whole system state
+type Snapshot struct {
+	Partitions []Partition `json:"partitions"`
+	Zones      []Zone      `json:"zones"` // Zones seen open at least once, by number
+	Troubles   Troubles    `json:"troubles"`
+	KeypadText string      `json:"keypad_text"` // Text of the most recent keypad update on any partition
+	Updated    time.Time   `json:"updated"`     // When any of the above other than keypad text last changed
+
+	// EventSequence is the Sequence of the last recorded event, so numbering
+	// carries on across restarts
+	EventSequence uint64 `json:"event_sequence"`
+}
+
+// Store maintains the alarm system state from TPI messages. It is safe for
+// concurrent use.
+type Store struct {
+	mu         sync.RWMutex
+	partitions map[int]*Partition
+	zones      map[int]*Zone
+	troubles   Troubles
+	keypadText string
+	updated    time.Time
+	changed    chan struct{} // Closed and replaced on every change, see WaitPartition
+
+	// Event history, see events.go
+	events     []Event
+	eventSeq   uint64
+	eventLimit int
+
+	// Snapshot persistence, see snapshot.go
+	saveMu       sync.Mutex
+	snapshotPath string
+	logger       *log.Logger
+	saveNeeded   chan struct{} // Signalled (non-blocking) after each change
+	saverDone    chan struct{} // Closed when the saver started by PersistTo exits
+}
+
+// New returns an empty Store
+func New() *Store {
+	return &Store{
+		partitions: make(map[int]*Partition),
+		zones:      make(map[int]*Zone),
+		changed:    make(chan struct{}),
+		eventLimit: DefaultEventHistory,
+	}
+}
+
+// Run applies messages until the channel is closed or ctx is cancelled.
+// Feed it from tpi.Client.Subscribe, or call Apply from tpi.Client.Handle so
+// no message is dropped.
+func (s *Store) Run(ctx context.Context, msgs <-chan tpi.Message) {
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			s.Apply(msg)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Apply updates the state from one message and reports whether anything changed
+func (s *Store) Apply(msg tpi.Message) bool {
+	if msg.Err != nil || msg.Packet == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	var changed bool
+	seq := s.eventSeq
+	switch p := msg.Packet.(type) {
+	case tpi.KeypadUpdate:
+		changed = s.applyKeypad(p, msg.Time)
+	case tpi.ZoneStateChange:
+		changed = s.applyZones(p, msg.Time)
+	case tpi.PartitionStateChange:
+		changed = s.applyPartitions(p, msg.Time)
+	case tpi.ZoneTimerDump:
+		changed = s.applyZoneTimers(p, msg.Time)
+	case tpi.RealtimeCID:
+		// Only history, the state comes from the packets that follow
+		s.recordCID(p, msg.Time)
+	}
+	if changed {
+		s.updated = msg.Time
+		close(s.changed)
+		s.changed = make(chan struct{})
+	}
+	recorded := s.eventSeq != seq
+	s.mu.Unlock()
+
+	if changed || recorded {
+		s.persist()
+	}
+	return changed
+}
+
+// partition returns partition n, creating it on first sight
+func (s *Store) partition(n int) *Partition {
+	p, ok := s.partitions[n]
+	if !ok {
+		p = &Partition{Number: n}
+		s.partitions[n] = p
+	}
+	return p
+}
+
+// zone returns zone n, creating it on first sight
+func (s *Store) zone(n int) *Zone {
+	z, ok := s.zones[n]
+	if !ok {
+		z = &Zone{Number: n}
+		s.zones[n] = z
+	}
+	return z
+}
+
+// applyKeypad takes the partition flags and system troubles from the keypad LEDs
+func (s *Store) applyKeypad(u tpi.KeypadUpdate, at time.Time) bool {
+	p := s.partition(u.Partition)
+	before := *p
+
+	leds := u.LEDs
+	p.Ready = leds.Ready
+	p.ArmedMode = armedModeFromLEDs(leds)
+	p.Alarm = leds.Alarm
+	p.AlarmMemory = leds.AlarmInMemory
+	p.Bypass = leds.Bypass
+	p.Chime = leds.Chime
+	if p.Stale {
+		// Only a %02 reports these, so a restored value cannot be confirmed here
+		p.State = ""
+		p.ExitDelay = false
+	}
+	p.Stale = false
+
+	// Keypads rotate through messages such as fault lists, so new text alone
+	// is kept but is not a change
+	flagsChanged := *p != before
+	if flagsChanged {
+		p.LastChange = at
+	}
+	p.KeypadText = u.Alpha
+
+	troubles := Troubles{
+		ACLoss:      !leds.ACPresent,
+		LowBattery:  leds.LowBattery,
+		SystemCheck: leds.Check,
+		Fire:        leds.Fire,
+	}
+
+	changed := flagsChanged || troubles != s.troubles
+	s.troubles = troubles
+	s.keypadText = u.Alpha
+	return changed
+}
+
+// applyPartitions takes the state of every partition in use from a %02
+func (s *Store) applyPartitions(c tpi.PartitionStateChange, at time.Time) bool {
+	changed := false
+	for i, state := range c.States {
+		n := i + 1
+		if state == tpi.PartitionNotUsed {
+			if _, ok := s.partitions[n]; ok {
+				delete(s.partitions, n)
+				changed = true
+			}
+			continue
+		}
+
+		p := s.partition(n)
+		before := *p
+		name := state.String()
+		p.State = name
+		p.ArmedMode = armedModeFromState(state)
+		p.Ready = state == tpi.PartitionReady || state == tpi.PartitionReadyBypass
+		p.ExitDelay = state == tpi.PartitionExitDelay
+		p.Alarm = state == tpi.PartitionInAlarm
+		p.Stale = false
+		if state == tpi.PartitionAlarmMemory {
+			p.AlarmMemory = true
+		}
+		if state == tpi.PartitionReadyBypass {
+			p.Bypass = true
+		}
+		if *p != before {
+			p.LastChange = at
+			changed = true
+		}
+		if before.State != name {
+			s.record(Event{Time: at, Type: EventPartitionState, Partition: n, State: name})
+		}
+	}
+	return changed
+}
+
+// applyZones takes the open/closed state of every zone from a %01
+func (s *Store) applyZones(c tpi.ZoneStateChange, at time.Time) bool {
+	changed := false
+	for n := 1; n <= c.ZoneCount; n++ {
+		open := c.IsOpen(n)
+		z, known := s.zones[n]
+		if !known {
+			if !open {
+				// Only zones seen open are tracked
+				continue
+			}
+			z = s.zone(n)
+		} else if z.Open == open && !z.Stale {
+			continue
+		}
+		changed = true
+		z.Stale = false
+		if known && z.Open == open {
+			continue
+		}
+		z.Open = open
+		z.LastChange = at
+		if open {
+			z.LastFaulted = at
+		}
+		s.recordZone(n, open, at)
+	}
+	return changed
+}
+
+// applyZoneTimers fills in open zones and last faulted times from a zone timer dump
+func (s *Store) applyZoneTimers(d tpi.ZoneTimerDump, at time.Time) bool {
+	changed := false
+	for _, timer := range d.Timers {
+		if timer.IsUnknown() {
+			continue
+		}
+
+		z, known := s.zones[timer.Zone]
+		if !known {
+			z = s.zone(timer.Zone)
+			changed = true
+		}
+		if z.Stale {
+			z.Stale = false
+			changed = true
+		}
+
+		if timer.IsOpen() {
+			if !z.Open {
+				z.Open = true
+				z.LastChange = at
+				changed = true
+				s.recordZone(timer.Zone, true, at)
+			}
+			continue
+		}
+
+		// The dump is only accurate to a timer tick, so keep an exact time from a %01
+		ago, _ := timer.LastFaulted()
+		faulted := at.Add(-ago)
+		if z.LastFaulted.IsZero() || z.LastFaulted.Before(faulted.Add(-tpi.ZoneTimerTick)) {
+			z.LastFaulted = faulted
+			changed = true
+		}
+		if z.Open {
+			z.Open = false
+			z.LastChange = at
+			changed = true
+			s.recordZone(timer.Zone, false, at)
+		}
+	}
+	return changed
+}
+
+// armedModeFromLEDs derives the armed mode from keypad LEDs
+func armedModeFromLEDs(leds tpi.KeypadLEDs) string {
+	switch {
+	case leds.ArmedAway && leds.ArmedZeroEntryDelay:
+		return ArmedMaximum
+	case leds.ArmedStay && leds.ArmedZeroEntryDelay:
+		return ArmedInstant
+	case leds.ArmedAway:
+		return ArmedAway
+	case leds.ArmedStay:
+		return ArmedStay
+	}
+	return ArmedNone
+}
+
+// armedModeFromState derives the armed mode from a partition state
+func armedModeFromState(state tpi.PartitionState) string {
+	switch state {
+	case tpi.PartitionArmedAway:
+		return ArmedAway
+	case tpi.PartitionArmedStay:
+		return ArmedStay
+	case tpi.PartitionArmedInstant:
+		return ArmedInstant
+	case tpi.PartitionArmedMaximum:
+		return ArmedMaximum
+	}
+	return ArmedNone
+}
+
+// Snapshot returns a copy of the current state
+func (s *Store) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := Snapshot{
+		Partitions: make([]Partition, 0, len(s.partitions)),
+		Zones:      make([]Zone, 0, len(s.zones)),
+		Troubles:   s.troubles,
+		KeypadText: s.keypadText,
+		Updated:    s.updated,
+
+		EventSequence: s.eventSeq,
+	}
+	for _, p := range s.partitions {
+		snap.Partitions = append(snap.Partitions, *p)
+	}
+	for _, z := range s.zones {
+		snap.Zones = append(snap.Zones, *z)
+	}
+	sort.Slice(snap.Partitions, func(i, j int) bool { return snap.Partitions[i].Number < snap.Partitions[j].Number })
+	sort.Slice(snap.Zones, func(i, j int) bool { return snap.Zones[i].Number < snap.Zones[j].Number })
+	return snap
+}
+
+// Partition returns the state of partition n. ok is false if it has not been seen.
+func (s *Store) Partition(n int) (Partition, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.partitions[n]
+	if !ok {
+		return Partition{}, false
+	}
+	return *p, true
+}
+
+// WaitPartition blocks until cond holds for partition n, checking now and after
+// every change. On ctx expiry it returns the last state seen with ctx.Err().
+func (s *Store) WaitPartition(ctx context.Context, n int, cond func(Partition) bool) (Partition, error) {
+	for {
+		s.mu.RLock()
+		p, ok := s.partitions[n]
+		var current Partition
+		if ok {
+			current = *p
+		}
+		changed := s.changed
+		s.mu.RUnlock()
+
+		if ok && cond(current) {
+			return current, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return current, ctx.Err()
+		}
+	}
+}
+
+// Zone returns the state of zone n. A zone never seen open is reported closed
+// with ok false.
+func (s *Store) Zone(n int) (Zone, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	z, ok := s.zones[n]
+	if !ok {
+		return Zone{Number: n}, false
+	}
+	return *z, true
+}
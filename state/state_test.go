@@ -0,0 +1,282 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"envisaMon/tpi"
+)
+
+var t0 = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// message parses a TPI line received at offset from t0
+func message(line string, offset time.Duration) tpi.Message {
+	packet, err := tpi.Parse(line)
+	return tpi.Message{Time: t0.Add(offset), Raw: line, Packet: packet, Err: err}
+}
+
+// zoneTimerDump builds a 64 zone timer dump with the given raw timers
+func zoneTimerDump(timers map[int]uint16) string {
+	var b strings.Builder
+	b.WriteString("%FF,")
+	for zone := 1; zone <= tpi.ZonesEVL3; zone++ {
+		raw := timers[zone]
+		fmt.Fprintf(&b, "%02X%02X", raw&0xFF, raw>>8)
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+func TestStore_Partitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  Partition
+	}{
+		{
+			name:  "ready from keypad",
+			lines: []string{"%00,01,1C08,08,00,****DISARMED****  Ready to Arm  $"},
+			want:  Partition{Number: 1, Ready: true, KeypadText: "****DISARMED****  Ready to Arm  "},
+		},
+		{
+			name:  "armed stay from keypad",
+			lines: []string{"%00,01,8008,00,05,ARMED ***STAY***May Exit Now  15$"},
+			want:  Partition{Number: 1, ArmedMode: ArmedStay, KeypadText: "ARMED ***STAY***May Exit Now  15"},
+		},
+		{
+			name:  "armed max from keypad",
+			lines: []string{"%00,01,008C,00,00,ARMED MAX$"},
+			want:  Partition{Number: 1, ArmedMode: ArmedMaximum, KeypadText: "ARMED MAX"},
+		},
+		{
+			name:  "alarm with chime and bypass from keypad",
+			lines: []string{"%00,01,0039,00,00,ALARM$"},
+			want:  Partition{Number: 1, Alarm: true, Chime: true, Bypass: true, KeypadText: "ALARM"},
+		},
+		{
+			name:  "armed away from partition state",
+			lines: []string{"%02,0500000000000000$"},
+			want:  Partition{Number: 1, State: "Armed Away", ArmedMode: ArmedAway},
+		},
+		{
+			name:  "exit delay from partition state",
+			lines: []string{"%02,0700000000000000$"},
+			want:  Partition{Number: 1, State: "Exit Delay", ExitDelay: true},
+		},
+		{
+			name:  "alarm memory survives disarm",
+			lines: []string{"%02,0800000000000000$", "%02,0900000000000000$"},
+			want:  Partition{Number: 1, State: "Alarm in Memory", AlarmMemory: true},
+		},
+		{
+			name:  "keypad then partition state",
+			lines: []string{"%00,01,1C08,08,00,READY$", "%02,0300000000000000$"},
+			want:  Partition{Number: 1, State: "Not Ready", KeypadText: "READY"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			for i, line := range tt.lines {
+				s.Apply(message(line, time.Duration(i)*time.Second))
+			}
+
+			got, ok := s.Partition(1)
+			if !ok {
+				t.Fatal("Partition(1) not found")
+			}
+			tt.want.LastChange = got.LastChange // Checked separately
+			if got != tt.want {
+				t.Errorf("Partition(1) = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_PartitionNotUsed(t *testing.T) {
+	s := New()
+	s.Apply(message("%02,0101000000000000$", 0))
+	if _, ok := s.Partition(2); !ok {
+		t.Fatal("Partition(2) not found after it was in use")
+	}
+
+	s.Apply(message("%02,0100000000000000$", time.Second))
+	if _, ok := s.Partition(2); ok {
+		t.Error("Partition(2) still present after it became unused")
+	}
+	if got := len(s.Snapshot().Partitions); got != 1 {
+		t.Errorf("Snapshot() has %d partitions, want 1", got)
+	}
+}
+
+func TestStore_Troubles(t *testing.T) {
+	s := New()
+	// AC missing, low battery, check and fire
+	s.Apply(message("%00,01,6200,00,00,TROUBLE$", 0))
+
+	want := Troubles{ACLoss: true, LowBattery: true, SystemCheck: true, Fire: true}
+	snap := s.Snapshot()
+	if snap.Troubles != want {
+		t.Errorf("Troubles = %+v, want %+v", snap.Troubles, want)
+	}
+	if snap.KeypadText != "TROUBLE" {
+		t.Errorf("KeypadText = %q, want TROUBLE", snap.KeypadText)
+	}
+
+	// Restored
+	s.Apply(message("%00,01,1C08,08,00,READY$", time.Second))
+	if got := s.Snapshot().Troubles; got != (Troubles{}) {
+		t.Errorf("Troubles after restore = %+v, want none", got)
+	}
+}
+
+func TestStore_KeypadTextIsNotAChange(t *testing.T) {
+	s := New()
+	if !s.Apply(message("%00,01,1C08,08,00,****DISARMED****  Ready to Arm  $", 0)) {
+		t.Fatal("Apply() of first keypad update = false, want changed")
+	}
+
+	// A keypad cycling through its fault list leaves the flags alone
+	for i, text := range []string{"FAULT 03", "FAULT 05", "FAULT 03"} {
+		if s.Apply(message("%00,01,1C08,08,00,"+text+"$", time.Duration(i+1)*time.Second)) {
+			t.Errorf("Apply() of keypad text %q = true, want unchanged", text)
+		}
+	}
+
+	p, _ := s.Partition(1)
+	if p.KeypadText != "FAULT 03" || !p.LastChange.Equal(t0) {
+		t.Errorf("Partition(1) = %+v, want latest text and last change at t0", p)
+	}
+	if snap := s.Snapshot(); snap.KeypadText != "FAULT 03" || !snap.Updated.Equal(t0) {
+		t.Errorf("Snapshot() text %q updated %v, want FAULT 03 at t0", snap.KeypadText, snap.Updated)
+	}
+}
+
+func TestStore_Zones(t *testing.T) {
+	s := New()
+
+	// Zones 1 and 64 open
+	if !s.Apply(message("%01,0100000000000080$", 0)) {
+		t.Error("Apply() of opening zones = false, want changed")
+	}
+	// Zone 1 closes
+	s.Apply(message("%01,0000000000000080$", 10*time.Second))
+	// Repeat is not a change
+	if s.Apply(message("%01,0000000000000080$", 20*time.Second)) {
+		t.Error("Apply() of repeated zone state = true, want unchanged")
+	}
+
+	z1, ok := s.Zone(1)
+	if !ok || z1.Open || !z1.LastChange.Equal(t0.Add(10*time.Second)) || !z1.LastFaulted.Equal(t0) {
+		t.Errorf("Zone(1) = %+v, ok %v, want closed at +10s, faulted at t0", z1, ok)
+	}
+	z64, ok := s.Zone(64)
+	if !ok || !z64.Open || !z64.LastFaulted.Equal(t0) {
+		t.Errorf("Zone(64) = %+v, ok %v, want open since t0", z64, ok)
+	}
+
+	// Never seen open: closed, not tracked
+	if z, ok := s.Zone(5); ok || z.Open {
+		t.Errorf("Zone(5) = %+v, ok %v, want closed and untracked", z, ok)
+	}
+	if got := len(s.Snapshot().Zones); got != 2 {
+		t.Errorf("Snapshot() has %d zones, want 2", got)
+	}
+}
+
+func TestStore_ZoneTimers(t *testing.T) {
+	s := New()
+	s.Apply(message(zoneTimerDump(map[int]uint16{
+		2: 0xFFFF, // Open
+		3: 0xFFFB, // Faulted 4 ticks ago
+	}), time.Minute))
+
+	z2, _ := s.Zone(2)
+	if !z2.Open {
+		t.Errorf("Zone(2) = %+v, want open", z2)
+	}
+	z3, ok := s.Zone(3)
+	if !ok || z3.Open || !z3.LastFaulted.Equal(t0.Add(time.Minute-4*tpi.ZoneTimerTick)) {
+		t.Errorf("Zone(3) = %+v, ok %v, want closed, faulted 20s before the dump", z3, ok)
+	}
+	if _, ok := s.Zone(4); ok {
+		t.Error("Zone(4) with unknown timer is tracked")
+	}
+}
+
+func TestStore_IgnoresParseErrors(t *testing.T) {
+	s := New()
+	if s.Apply(message("%01,XYZ$", 0)) {
+		t.Error("Apply() of invalid line = true, want unchanged")
+	}
+	if !s.Snapshot().Updated.IsZero() {
+		t.Error("Updated set by invalid line")
+	}
+}
+
+func TestStore_Run(t *testing.T) {
+	s := New()
+	msgs := make(chan tpi.Message, 2)
+	msgs <- message("%02,0500000000000000$", 0)
+	close(msgs)
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(context.Background(), msgs)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return when the channel closed")
+	}
+	if p, _ := s.Partition(1); p.ArmedMode != ArmedAway {
+		t.Errorf("Partition(1).ArmedMode = %q, want away", p.ArmedMode)
+	}
+}
+
+func TestStore_WaitPartition(t *testing.T) {
+	armed := func(p Partition) bool { return p.ArmedMode == ArmedAway }
+
+	t.Run("already true", func(t *testing.T) {
+		s := New()
+		s.Apply(message("%02,0500000000000000$", 0))
+		p, err := s.WaitPartition(context.Background(), 1, armed)
+		if err != nil || p.ArmedMode != ArmedAway {
+			t.Errorf("WaitPartition() = %+v, %v, want armed away", p, err)
+		}
+	})
+
+	t.Run("becomes true", func(t *testing.T) {
+		s := New()
+		s.Apply(message("%02,0100000000000000$", 0))
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			s.Apply(message("%02,0700000000000000$", time.Second)) // Exit delay
+			s.Apply(message("%02,0500000000000000$", 2*time.Second))
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		p, err := s.WaitPartition(ctx, 1, armed)
+		if err != nil || p.ArmedMode != ArmedAway {
+			t.Errorf("WaitPartition() = %+v, %v, want armed away", p, err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		s := New()
+		s.Apply(message("%02,0100000000000000$", 0))
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		p, err := s.WaitPartition(ctx, 1, armed)
+		if err != context.DeadlineExceeded || p.State != "Ready" {
+			t.Errorf("WaitPartition() = %+v, %v, want last state Ready and deadline exceeded", p, err)
+		}
+	})
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spkiPin returns the pin for a certificate: the base64 SHA-256 of its
+// SubjectPublicKeyInfo, as produced by
+//
+//	openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// parsePins normalises pins given as base64 or "sha256/base64"
+func parsePins(pins []string) (map[string]bool, error) {
+	set := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		p := strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+		sum, err := base64.StdEncoding.DecodeString(p)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid SPKI pin %q: want base64 SHA-256", pin)
+		}
+		set[p] = true
+	}
+	return set, nil
+}
+
+// newTLSConfig builds the reporter's TLS settings. Certificates are verified
+// against the system roots by default, or against CAFile if set. With pins the
+// server's key must match one of them: without CAFile a pinned leaf alone
+// authenticates the server, which suits self-signed certificates; otherwise the
+// pin must be in a chain that verifies.
+func newTLSConfig(url string, opts ReporterOptions, errorWriter io.Writer) (*tls.Config, error) {
+	cfg := &tls.Config{}
+
+	if opts.ClientCertFile != "" || opts.ClientKeyFile != "" {
+		loader, err := newClientCertLoader(opts.ClientCertFile, opts.ClientKeyFile, errorWriter)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = loader.GetClientCertificate
+	}
+
+	if opts.InsecureSkipVerify {
+		if opts.CAFile != "" || len(opts.PinnedSPKI) > 0 {
+			return nil, errors.New("insecure TLS cannot be combined with a CA file or pins")
+		}
+		fmt.Fprintf(errorWriter, "AsyncReporter WARNING: TLS certificate verification disabled for %s, events and the API key can be intercepted\n", url)
+		cfg.InsecureSkipVerify = true
+		return cfg, nil
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(opts.PinnedSPKI) == 0 {
+		return cfg, nil
+	}
+
+	pins, err := parsePins(opts.PinnedSPKI)
+	if err != nil {
+		return nil, err
+	}
+
+	// Standard verification is replaced by VerifyConnection so a pinned
+	// self-signed certificate is accepted
+	roots := cfg.RootCAs
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		return verifyPinned(cs, roots, pins)
+	}
+	return cfg, nil
+}
+
+// verifyPinned checks the server against the pins. Only the leaf's key is
+// proven by the handshake and the rest of the presented list is chosen by the
+// server, so a pin matches either the leaf directly (when no CA file is set) or
+// a certificate in a chain that verifies against roots (nil for the system roots).
+func verifyPinned(cs tls.ConnectionState, roots *x509.CertPool, pins map[string]bool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+	if roots == nil && pins[spkiPin(leaf)] {
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		if roots == nil {
+			return fmt.Errorf("server certificate for %s does not match any pinned key and is not trusted: %w", cs.ServerName, err)
+		}
+		return err
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if pins[spkiPin(cert)] {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("server certificate for %s does not match any pinned key", cs.ServerName)
+}
+
+// clientCertLoader supplies the mTLS client certificate, reloading it when the
+// certificate or key file changes so renewed certificates are picked up
+// without a restart
+type clientCertLoader struct {
+	certFile    string
+	keyFile     string
+	errorWriter io.Writer
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func newClientCertLoader(certFile, keyFile string, errorWriter io.Writer) (*clientCertLoader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("client certificate and key must both be set")
+	}
+	l := &clientCertLoader{certFile: certFile, keyFile: keyFile, errorWriter: errorWriter}
+	if err := l.reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// reload loads the key pair if either file changed since the last load
+func (l *clientCertLoader) reload() error {
+	certInfo, err := os.Stat(l.certFile)
+	if err != nil {
+		return fmt.Errorf("client certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(l.keyFile)
+	if err != nil {
+		return fmt.Errorf("client key: %w", err)
+	}
+	if l.cert != nil && certInfo.ModTime().Equal(l.certMod) && keyInfo.ModTime().Equal(l.keyMod) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading client certificate: %w", err)
+	}
+	l.cert = &cert
+	l.certMod = certInfo.ModTime()
+	l.keyMod = keyInfo.ModTime()
+	return nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate. If a
+// changed pair fails to load, e.g. mid-rotation, the previous one is kept.
+func (l *clientCertLoader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.reload(); err != nil {
+		fmt.Fprintf(l.errorWriter, "AsyncReporter client certificate reload error, keeping previous: %v\n", err)
+	}
+	return l.cert, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewAsyncReporter_TLSVerification(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	serverPin := spkiPin(ts.Certificate())
+	caFile := trustServer(t, ts).CAFile
+
+	tests := []struct {
+		name        string
+		opts        ReporterOptions
+		wantSetup   string // Substring of the constructor error, if any
+		wantSendOK  bool
+		wantWarning bool
+	}{
+		{name: "verified by default", opts: ReporterOptions{}, wantSendOK: false},
+		{name: "custom CA", opts: ReporterOptions{CAFile: caFile}, wantSendOK: true},
+		{name: "matching pin", opts: ReporterOptions{PinnedSPKI: []string{serverPin}}, wantSendOK: true},
+		{name: "matching pin with prefix", opts: ReporterOptions{PinnedSPKI: []string{"sha256/" + serverPin}}, wantSendOK: true},
+		{name: "pin and CA", opts: ReporterOptions{CAFile: caFile, PinnedSPKI: []string{serverPin}}, wantSendOK: true},
+		{name: "wrong pin", opts: ReporterOptions{PinnedSPKI: []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}}, wantSendOK: false},
+		{name: "insecure", opts: ReporterOptions{InsecureSkipVerify: true}, wantSendOK: true, wantWarning: true},
+		{name: "invalid pin", opts: ReporterOptions{PinnedSPKI: []string{"not-a-hash"}}, wantSetup: "invalid SPKI pin"},
+		{name: "missing CA file", opts: ReporterOptions{CAFile: "/nonexistent/ca.pem"}, wantSetup: "reading CA file"},
+		{name: "insecure with pin", opts: ReporterOptions{InsecureSkipVerify: true, PinnedSPKI: []string{serverPin}}, wantSetup: "cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errorWriter := &strings.Builder{}
+			reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, errorWriter, tt.opts)
+			if tt.wantSetup != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantSetup) {
+					t.Fatalf("NewAsyncReporterWithOptions() error = %v, want %q", err, tt.wantSetup)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+			}
+			defer reporter.Close(context.Background())
+
+			if got := strings.Contains(errorWriter.String(), "verification disabled"); got != tt.wantWarning {
+				t.Errorf("insecure warning logged = %v, want %v", got, tt.wantWarning)
+			}
+
+			reporter.errorWriter = io.Discard
+			err = reporter.send([]Event{{EventID: "1", EventMessage: "test"}})
+			if (err == nil) != tt.wantSendOK {
+				t.Errorf("send() error = %v, want success %v", err, tt.wantSendOK)
+			}
+		})
+	}
+}
+
+// writeClientCert creates a client certificate signed by ca (self-signed if
+// nil) and writes the PEM pair to certFile and keyFile
+func writeClientCert(t *testing.T, serial int64, ca *tls.Certificate, certFile, keyFile string) *tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "envisamon-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  ca == nil,
+	}
+	parent, signer := tmpl, any(key)
+	if ca != nil {
+		parent, signer = ca.Leaf, ca.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestNewAsyncReporter_ClientCertificate(t *testing.T) {
+	// No API key: the client certificate alone authenticates
+	os.Unsetenv("ALARM_MON_API_KEY")
+
+	dir := t.TempDir()
+	ca := writeClientCert(t, 1, nil, filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem"))
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	writeClientCert(t, 100, ca, certFile, keyFile)
+
+	serials := make(chan int64, 10)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "" {
+			t.Errorf("unexpected X-API-Key header")
+		}
+		serials <- r.TLS.PeerCertificates[0].SerialNumber.Int64()
+		w.WriteHeader(http.StatusOK)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Leaf)
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	ts.StartTLS()
+	defer ts.Close()
+
+	opts := trustServer(t, ts)
+	opts.ClientCertFile, opts.ClientKeyFile = certFile, keyFile
+	reporter, err := NewAsyncReporterWithOptions(ts.URL, "test-system", "TPI", false, io.Discard, opts)
+	if err != nil || reporter == nil {
+		t.Fatalf("NewAsyncReporterWithOptions() = %v, %v", reporter, err)
+	}
+	defer reporter.Close(context.Background())
+
+	if err := reporter.send([]Event{{EventID: "1"}}); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	if got := <-serials; got != 100 {
+		t.Errorf("client certificate serial = %d, want 100", got)
+	}
+
+	// Rotate the certificate; new connections present the new one
+	writeClientCert(t, 200, ca, certFile, keyFile)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	reporter.client.CloseIdleConnections()
+
+	if err := reporter.send([]Event{{EventID: "2"}}); err != nil {
+		t.Fatalf("send() after rotation error = %v", err)
+	}
+	if got := <-serials; got != 200 {
+		t.Errorf("client certificate serial after rotation = %d, want 200", got)
+	}
+}
+
+func TestNewAsyncReporter_ClientCertificateErrors(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	writeClientCert(t, 1, nil, certFile, keyFile)
+
+	tests := []struct {
+		name string
+		opts ReporterOptions
+		want string
+	}{
+		{"key missing", ReporterOptions{ClientCertFile: certFile}, "must both be set"},
+		{"cert file missing", ReporterOptions{ClientCertFile: filepath.Join(dir, "nope.pem"), ClientKeyFile: keyFile}, "client certificate"},
+		{"mismatched pair", ReporterOptions{ClientCertFile: keyFile, ClientKeyFile: certFile}, "loading client certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAsyncReporterWithOptions("https://example.com", "test-system", "TPI", false, io.Discard, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewAsyncReporterWithOptions() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAsyncReporter_PinIgnoresUnprovenCertificates(t *testing.T) {
+	os.Setenv("ALARM_MON_API_KEY", "test-key")
+	defer os.Unsetenv("ALARM_MON_API_KEY")
+
+	// The genuine server, whose key is pinned and whose certificate is public
+	real := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer real.Close()
+	pin := spkiPin(real.Certificate())
+	caFile := trustServer(t, real).CAFile
+
+	// A man in the middle presenting its own leaf followed by the genuine certificate
+	dir := t.TempDir()
+	attacker := writeClientCert(t, 7, nil, filepath.Join(dir, "mitm.pem"), filepath.Join(dir, "mitm-key.pem"))
+	attacker.Certificate = append(attacker.Certificate, real.Certificate().Raw)
+	var requests atomic.Int64
+	mitm := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	mitm.TLS = &tls.Config{Certificates: []tls.Certificate{*attacker}}
+	mitm.StartTLS()
+	defer mitm.Close()
+
+	tests := []struct {
+		name string
+		opts ReporterOptions
+	}{
+		{name: "pin only", opts: ReporterOptions{PinnedSPKI: []string{pin}}},
+		{name: "pin and CA", opts: ReporterOptions{CAFile: caFile, PinnedSPKI: []string{pin}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			reporter, err := NewAsyncReporterWithOptions(mitm.URL, "test-system", "TPI", false, io.Discard, tt.opts)
+			if err != nil {
+				t.Fatalf("NewAsyncReporterWithOptions() error = %v", err)
+			}
+			defer reporter.Close(context.Background())
+
+			err = reporter.send([]Event{{EventID: "1", EventMessage: "test"}})
+			if err == nil || requests.Load() != 0 {
+				t.Errorf("send() error = %v after %d requests, want the handshake to fail", err, requests.Load())
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package tpi
+
+import "sync"
+
+// ackResult is delivered to a pending command when its ack arrives or the link drops
+type ackResult struct {
+	ack CommandAck
+	err error
+}
+
+// pendingCommand is an application command waiting for its ^CC,EE$ reply
+type pendingCommand struct {
+	code string
+	done chan ackResult
+}
+
+// commandTracker matches acknowledgements to outstanding commands in send order
+type commandTracker struct {
+	mu      sync.Mutex
+	pending []*pendingCommand
+}
+
+// add registers a command that is about to be sent
+func (t *commandTracker) add(code string) *pendingCommand {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := &pendingCommand{code: code, done: make(chan ackResult, 1)}
+	t.pending = append(t.pending, p)
+	return p
+}
+
+// remove forgets a command, e.g. after it timed out
+func (t *commandTracker) remove(p *pendingCommand) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, q := range t.pending {
+		if q == p {
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// resolve hands an ack to the oldest pending command with the same code.
+// It returns false if nothing was waiting for it.
+func (t *commandTracker) resolve(ack CommandAck) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, p := range t.pending {
+		if p.code == ack.Code {
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			p.done <- ackResult{ack: ack}
+			return true
+		}
+	}
+	return false
+}
+
+// failAll releases every pending command with err
+func (t *commandTracker) failAll(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, p := range t.pending {
+		p.done <- ackResult{err: err}
+	}
+	t.pending = nil
+}
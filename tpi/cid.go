@@ -0,0 +1,121 @@
+package tpi
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CIDCategory groups Contact ID event codes as in Ademco-contact-id.md
+type CIDCategory string
+
+const (
+	CIDMedical         CIDCategory = "Medical"
+	CIDFire            CIDCategory = "Fire"
+	CIDPanic           CIDCategory = "Panic"
+	CIDBurglar         CIDCategory = "Burglar"
+	CIDGeneralAlarm    CIDCategory = "General Alarm"
+	CIDNonBurglary     CIDCategory = "24 Hour Non-Burglary"
+	CIDFireSupervisory CIDCategory = "Fire Supervisory"
+	CIDTrouble         CIDCategory = "Trouble"
+	CIDOpenClose       CIDCategory = "Open/Close"
+	CIDRemoteAccess    CIDCategory = "Remote Access"
+	CIDAccessControl   CIDCategory = "Access Control"
+	CIDDisable         CIDCategory = "Disable"
+	CIDBypass          CIDCategory = "Bypass"
+	CIDTest            CIDCategory = "Test"
+	CIDEventLog        CIDCategory = "Event Log"
+	CIDScheduling      CIDCategory = "Scheduling"
+	CIDPersonnel       CIDCategory = "Personnel Monitoring"
+	CIDMiscellaneous   CIDCategory = "Miscellaneous"
+	CIDUnknown         CIDCategory = "Unknown"
+)
+
+// CIDQualifier distinguishes a new event from a restoral
+type CIDQualifier int
+
+const (
+	CIDEvent   CIDQualifier = 1
+	CIDRestore CIDQualifier = 3
+)
+
+func (q CIDQualifier) String() string {
+	switch q {
+	case CIDEvent:
+		return "event"
+	case CIDRestore:
+		return "restore"
+	}
+	return fmt.Sprintf("unknown (%d)", int(q))
+}
+
+// cidCode is one row of the Contact ID event table
+type cidCode struct {
+	Category     CIDCategory
+	Description  string
+	ReportString string
+	User         bool // The ZZZ field carries a user number rather than a zone
+}
+
+// lookupCIDCode returns the table entry for an event code
+func lookupCIDCode(code int) (cidCode, bool) {
+	if c, ok := cidCodes[code]; ok {
+		return c, true
+	}
+	if code >= 750 && code <= 789 {
+		return cidCode{Category: CIDMiscellaneous, Description: "Protection One Use"}, true
+	}
+	return cidCode{Category: CIDUnknown, Description: fmt.Sprintf("Unknown CID code %03d", code)}, false
+}
+
+// RealtimeCID is a Realtime Contact ID event (%03)
+type RealtimeCID struct {
+	Frame
+	Qualifier    CIDQualifier
+	Code         int // 3 digit Contact ID event code
+	Category     CIDCategory
+	Description  string
+	ReportString string
+	Partition    int
+	ZoneOrUser   int  // Zone number, or user number when IsUser is set
+	IsUser       bool // ZoneOrUser is a user number (e.g. open/close by user)
+}
+
+func (c RealtimeCID) String() string {
+	who := "zone"
+	if c.IsUser {
+		who = "user"
+	}
+	return fmt.Sprintf("%s %03d %s [%s] partition %d %s %d", c.Qualifier, c.Code, c.Description, c.Category, c.Partition, who, c.ZoneOrUser)
+}
+
+// parseRealtimeCID decodes the BCD QXXXPPZZZ0 payload
+func parseRealtimeCID(f Frame) (Packet, error) {
+	if len(f.Data) != 10 {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("CID event is %d digits, want 10", len(f.Data))}
+	}
+	for i := 0; i < len(f.Data); i++ {
+		if f.Data[i] < '0' || f.Data[i] > '9' {
+			return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("CID event %q is not decimal", f.Data)}
+		}
+	}
+
+	// All fields are known to be decimal digits so Atoi cannot fail
+	qualifier, _ := strconv.Atoi(f.Data[0:1])
+	code, _ := strconv.Atoi(f.Data[1:4])
+	partition, _ := strconv.Atoi(f.Data[4:6])
+	zoneOrUser, _ := strconv.Atoi(f.Data[6:9])
+
+	entry, _ := lookupCIDCode(code)
+
+	return RealtimeCID{
+		Frame:        f,
+		Qualifier:    CIDQualifier(qualifier),
+		Code:         code,
+		Category:     entry.Category,
+		Description:  entry.Description,
+		ReportString: entry.ReportString,
+		Partition:    partition,
+		ZoneOrUser:   zoneOrUser,
+		IsUser:       entry.User,
+	}, nil
+}
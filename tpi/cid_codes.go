@@ -0,0 +1,264 @@
+package tpi
+
+// cidCodes is the Ademco Contact ID event table (see Ademco-contact-id.md) keyed
+// by 3 digit event code. Codes 750-789 (Protection One Use) are handled by lookupCIDCode.
+var cidCodes = map[int]cidCode{
+	100: {CIDMedical, "Medical", "Emerg-Personal Emergency-#", false},
+	101: {CIDMedical, "Pendant Transmitter", "Emerg-Personal Emergency-#", false},
+	102: {CIDMedical, "Fail to report in", "Emerg-Fail to check in-#", false},
+	110: {CIDFire, "FIRE", "Fire-Fire Alarm-#", false},
+	111: {CIDFire, "SMOKE w/VERIFICATION", "Fire-Fire Alarm-#", false},
+	112: {CIDFire, "Combustion", "Fire-Combustion-#", false},
+	113: {CIDFire, "WATERFLOW", "Fire-Water Flow-#", false},
+	114: {CIDFire, "Heat", "Fire-Heat Sensor-#", false},
+	115: {CIDFire, "Pull Station", "Fire-Pull Station-#", false},
+	116: {CIDFire, "Duct", "Fire-Duct Sensor-#", false},
+	117: {CIDFire, "Flame", "Fire-Flame Sensor-#", false},
+	118: {CIDFire, "Near Alarm", "Fire-Near Alarm-#", false},
+	120: {CIDPanic, "Panic Alarm", "Panic-Panic-#", false},
+	121: {CIDPanic, "DURESS", "Panic-Duress- User 000 (or duress zone on low end panels)", true},
+	122: {CIDPanic, "SILENT", "Panic-Silent Panic-#", false},
+	123: {CIDPanic, "AUDIBLE", "Panic-Audible Panic-#", false},
+	124: {CIDPanic, "Duress-Access Granted", "Panic-Duress Access Grant-#", false},
+	125: {CIDPanic, "Duress-Egress Granted", "Panic-Duress Egress Grant-#", false},
+	130: {CIDBurglar, "Burglary", "Burg-Burglary-#", false},
+	131: {CIDBurglar, "PERIMETER", "Burg-Perimeter-#", false},
+	132: {CIDBurglar, "INTERIOR", "Burg-Interior-#", false},
+	133: {CIDBurglar, "24 HR BURG (AUX)", "Burg-24 Hour-#", false},
+	134: {CIDBurglar, "ENTRY/EXIT", "Burg-Entry/Exit-#", false},
+	135: {CIDBurglar, "DAY/NIGHT", "Burg-Day/Night-#", false},
+	136: {CIDBurglar, "Outdoor", "Burg-Outdoor-#", false},
+	137: {CIDBurglar, "TAMPER", "Burg-Tamper-#", false},
+	138: {CIDBurglar, "Near Alarm", "Burg-Near Alarm-#", false},
+	139: {CIDBurglar, "Intrusion Verifier", "Burg-Intrusion Verifier-#", false},
+	140: {CIDGeneralAlarm, "General Alarm", "Alarm-General Alarm-#", false},
+	141: {CIDGeneralAlarm, "Polling Loop Open", "Alarm-Polling Loop Open", false},
+	142: {CIDGeneralAlarm, "POLLING LOOP SHORT (AL)", "Alarm-Polling Loop Short", false},
+	143: {CIDGeneralAlarm, "EXPANSION MOD FAILURE", "Alarm-Exp. Module Tamper-#", false},
+	144: {CIDGeneralAlarm, "Sensor Tamper", "Alarm-Sensor Tamper-#", false},
+	145: {CIDGeneralAlarm, "Expansion Module Tamper", "Alarm-Exp. Module Tamper-#", false},
+	146: {CIDGeneralAlarm, "SILENT BURG", "Burg-Silent Burglary-#", false},
+	147: {CIDGeneralAlarm, "Sensor Supervision", "Trouble Sensor Super. -#", false},
+	150: {CIDNonBurglary, "24 HOUR (AUXILIARY)", "Alarm-24 Hr. Non-Burg-#", false},
+	151: {CIDNonBurglary, "Gas Detected", "Alarm-Gas Detected-#", false},
+	152: {CIDNonBurglary, "Refrigeration", "Alarm-Refrigeration-#", false},
+	153: {CIDNonBurglary, "Loss of Heat", "Alarm-Heating System-#", false},
+	154: {CIDNonBurglary, "Water Leakage", "Alarm-Water Leakage-#", false},
+	155: {CIDNonBurglary, "Foil Break", "Trouble-Foil Break-#", false},
+	156: {CIDNonBurglary, "Day Trouble", "Trouble-Day Zone-#", false},
+	157: {CIDNonBurglary, "Low Bottled Gas Level", "Alarm-Low Gas Level-#", false},
+	158: {CIDNonBurglary, "High Temp", "Alarm-High Temperature-#", false},
+	159: {CIDNonBurglary, "Low Temp", "Alarm-Low Temperature-#", false},
+	161: {CIDNonBurglary, "Loss of Air Flow", "Alarm-Air Flow-#", false},
+	162: {CIDNonBurglary, "Carbon Monoxide Detected", "Alarm-Carbon Monoxide-#", false},
+	163: {CIDNonBurglary, "Tank Level", "Trouble-Tank Level-#", false},
+	168: {CIDNonBurglary, "High Humidity", "Trouble-High Humidity-#", false},
+	169: {CIDNonBurglary, "Low Humidity", "Trouble-Low Humidity-#", false},
+	200: {CIDFireSupervisory, "FIRE SUPERVISORY", "Super.-Fire Supervisory-#", false},
+	201: {CIDFireSupervisory, "Low Water Pressure", "Super-Low Water Pressure-#", false},
+	202: {CIDFireSupervisory, "Low CO2", "Super.-Low CO2-#", false},
+	203: {CIDFireSupervisory, "Gate Valve Sensor", "Super.-Gate Valve-#", false},
+	204: {CIDFireSupervisory, "Low Water Level", "Super.-Low Water Level-#", false},
+	205: {CIDFireSupervisory, "Pump Activated", "Super.-Pump Activation-#", false},
+	206: {CIDFireSupervisory, "Pump Failure", "Super.-Pump Failure-#", false},
+	300: {CIDTrouble, "System Trouble", "Trouble-System Trouble", false},
+	301: {CIDTrouble, "AC LOSS", "Trouble-AC Power", false},
+	302: {CIDTrouble, "LOW SYSTEM BATT", "Trouble-Low Battery (AC is lost, battery is getting low)", false},
+	303: {CIDTrouble, "RAM Checksum Bad", "Trouble-Bad RAM Checksum (Restore Not Applicable)", false},
+	304: {CIDTrouble, "ROM Checksum Bad", "Trouble-Bad ROM Checksum (Restore Not Applicable)", false},
+	305: {CIDTrouble, "SYSTEM RESET", "Trouble-System Reset (Restore Not Applicable)", false},
+	306: {CIDTrouble, "PANEL PROG CHANGE", "Trouble-Programming Changed (Restore Not Applicable)", false},
+	307: {CIDTrouble, "Self-Test Failure", "Trouble-Self Test Failure", false},
+	308: {CIDTrouble, "System Shutdown", "Trouble-System Shutdown", false},
+	309: {CIDTrouble, "Battery Test Fail", "Trouble-Battery Test Failure (Battery failed at test interval)", false},
+	310: {CIDTrouble, "GROUND FAULT", "Trouble-Ground Fault-#", false},
+	311: {CIDTrouble, "Battery Missing", "Trouble-Battery Missing", false},
+	312: {CIDTrouble, "Power Supply Overcurrent", "Trouble-Pwr. Supp. Overcur.-#", false},
+	313: {CIDTrouble, "Engineer Reset", "Status-Engineer Reset - User # (Restore Not Applicable)", true},
+	314: {CIDTrouble, "Primary Power Supply Failure", "Trouble - Pri Pwr Supply Fail - # (Sent by UL864 Rev 9 Fire panels like FBP)", false},
+	316: {CIDTrouble, "System Tamper", "Trouble - APL System trouble - #", false},
+	320: {CIDTrouble, "SOUNDER/RELAY", "Trouble-Sounder/Relay-#", false},
+	321: {CIDTrouble, "BELL 1", "Trouble-Bell/Siren #1 (Event and Restore)", false},
+	322: {CIDTrouble, "BELL 2", "Trouble-Bell/Siren #2 (Event and Restore)", false},
+	323: {CIDTrouble, "Alarm Relay", "Trouble-Alarm Relay", false},
+	324: {CIDTrouble, "Trouble Relay", "Trouble-Trouble Relay", false},
+	325: {CIDTrouble, "Reversing Relay", "Trouble-Reversing Relay", false},
+	326: {CIDTrouble, "Notification Appliance Ckt. #3", "Trouble-Notification Appl. Ckt#3", false},
+	327: {CIDTrouble, "Notification Appliance Ckt. #4", "Trouble-Notification Appl. Ckt#4", false},
+	330: {CIDTrouble, "System Peripheral (E355)", "Trouble-Sys. Peripheral-# From LRR, ECP data connection to panel", false},
+	331: {CIDTrouble, "Polling Loop Open", "Trouble-Polling Loop Open", false},
+	332: {CIDTrouble, "POLLING LOOP SHORT", "Trouble-Polling Loop Short", false},
+	333: {CIDTrouble, "Exp. Module Failure", "Trouble-Exp. Module Fail-# ECP Path problem between panel to LRR, etc", false},
+	334: {CIDTrouble, "Repeater Failure", "Trouble-Repeater Failure-#", false},
+	335: {CIDTrouble, "Local Printer Paper Out", "Trouble-Printer Paper Out", false},
+	336: {CIDTrouble, "Local Printer Failure", "Trouble-Local Printer", false},
+	337: {CIDTrouble, "EXP. MOD. DC LOSS", "Trouble-Exp. Mod. DC Loss-#", false},
+	338: {CIDTrouble, "EXP. MOD. LOW BAT", "Trouble-Exp. Mod. Low Batt-#", false},
+	339: {CIDTrouble, "EXP. MOD. RESET", "Trouble-Exp. Mod. Reset-#", false},
+	341: {CIDTrouble, "EXP. MOD. TAMPER", "Trouble-Exp. Mod. Tamper-# (5881ENHC)", false},
+	342: {CIDTrouble, "Exp. Module AC Loss", "Trouble-Exp. Module AC Loss-#", false},
+	343: {CIDTrouble, "Exp. Module Self Test Fail", "Trouble-Exp. Self-Test Fail-#", false},
+	344: {CIDTrouble, "RF Rcvr Jam Detect #", "Trouble-RF Rcvr Jam Detect-#", false},
+	345: {CIDTrouble, "AES Encryption disabled/enabled", "Trouble-AES Encryption", false},
+	350: {CIDTrouble, "Communication", "Trouble-Communication Failure", false},
+	351: {CIDTrouble, "TELCO 1 FAULT", "Trouble-Phone line # 1 (Comes in as zone 1 on a V20P panel)", false},
+	352: {CIDTrouble, "TELCO 2 FAULT", "Trouble-Phone Line # 2", false},
+	353: {CIDTrouble, "LR Radion Xmitter Fault (333)", "Trouble-Radio Transmitter - Comm Path problem between panel and lrr (Old)", false},
+	354: {CIDTrouble, "FAILURE TO COMMUNICATE", "Trouble-Fail to Communicate", false},
+	355: {CIDTrouble, "Loss of Radio Super. (R330)", "Trouble-Radio Supervision - From LRR - ECP data connection to panel", false},
+	356: {CIDTrouble, "Loss of Central Polling", "Trouble-Central Radion Polling", false},
+	357: {CIDTrouble, "LRR XMTR. VSWR", "Trouble-Radio Xmitter. VSWR-#", false},
+	370: {CIDTrouble, "Protection Loop", "Trouble-Protection Loop-#", false},
+	371: {CIDTrouble, "Protection Loop Open", "Trouble-Prot. Loop Open-#", false},
+	372: {CIDTrouble, "Protection Loop Short", "Trouble-Prot. Loop Short-#", false},
+	373: {CIDTrouble, "FIRE TROUBLE", "Trouble-Fire Loop-# (Supervision Loss, base tamper, Supervisory open)", false},
+	374: {CIDTrouble, "EXIT ERROR (BY USER)", "Alarm-Exit Error-#", false},
+	375: {CIDTrouble, "Panic Zone Trouble", "Trouble-PA Trouble-#", false},
+	376: {CIDTrouble, "Hold-Up Zone Trouble", "Trouble-Hold-Up Trouble-#", false},
+	377: {CIDTrouble, "Swinger Trouble", "Trouble - Swinger Trouble-#", false},
+	378: {CIDTrouble, "Cross-zone Trouble", "Trouble - Cross Zone Trouble - # (restore not applicable)", false},
+	380: {CIDTrouble, "SENSOR TRBL - GLOBAL", "Trouble-Sensor Trouble-# (zone type 5 and 19)", false},
+	381: {CIDTrouble, "LOSS OF SUPERVISION", "Trouble-RF Sensor Super.-#", false},
+	382: {CIDTrouble, "LOSS OF SUPRVSN", "Trouble-RPM Sensor Super.-#", false},
+	383: {CIDTrouble, "SENSOR TAMPER", "Trouble-Sensor Tamper-# (Cover or Base)", false},
+	384: {CIDTrouble, "RF LOW BATTERY", "Trouble-RF Sensor Battery-#", false},
+	385: {CIDTrouble, "SMOKE HI SENS.", "Trouble-Smoke Hi Sens.-#", false},
+	386: {CIDTrouble, "SMOKE LO SENS.", "Trouble-Smoke Lo Sens.-#", false},
+	387: {CIDTrouble, "INTRUSION HI SENS.", "Trouble-Intrusion Hi Sens.-#", false},
+	388: {CIDTrouble, "INTRUSION LO SENS.", "Trouble-Intrusion Lo Sens.-# (Similar to smart smoke detectors)", false},
+	389: {CIDTrouble, "DET. SELF TEST FAIL", "Trouble-Sensor Test Fail-# (see Direct Wire #84)", false},
+	391: {CIDTrouble, "Sensor Watch Failure", "Trouble-Sensor Watch Fail-#", false},
+	392: {CIDTrouble, "Drift Comp. Error", "Trouble-Drift Comp. Error-# (Reported by Firelite panels)", false},
+	393: {CIDTrouble, "Maintenance Alert", "Trouble-Maintenance Alert-#", false},
+	400: {CIDOpenClose, "Open/Close", "Opening/Closing (E= Open, R= Close)", true},
+	401: {CIDOpenClose, "OPEN/CLOSE BY USER", "Opening-User # / Closing-User #", true},
+	402: {CIDOpenClose, "Group O/C", "Closing-Group User #", true},
+	403: {CIDOpenClose, "AUTOMATIC OPEN/CLOSE", "Opening-Automatic / Closing-Automatic (power up Armed)", true},
+	404: {CIDOpenClose, "Late to O/C", "Opening-Late / Closing-Late", true},
+	405: {CIDOpenClose, "Deferred O/C", "Event & Restore Not Applicable", true},
+	406: {CIDOpenClose, "CANCEL (BY USER)", "Opening-Cancel", true},
+	407: {CIDOpenClose, "REMOTE ARM/DISARM", "Opening-Remote / Closing-Remote", true},
+	408: {CIDOpenClose, "QUICK ARM", "Event Not Applicable for opening / Closing-Quick Arm", true},
+	409: {CIDOpenClose, "KEYSWITCH OPEN/CLOSE", "Opening-Keyswitch / Closing-Keyswitch", true},
+	435: {CIDOpenClose, "Second Person Access", "ACCESS- User #", true},
+	436: {CIDOpenClose, "Irregular Access", "ACCESS-Irregular Access - User #", true},
+	441: {CIDOpenClose, "Armed Stay", "Opening-Armed Stay / Closing-Armed Stay", true},
+	442: {CIDOpenClose, "Keyswitch Armed Stay", "Opening-Keysw. Arm Stay", true},
+	450: {CIDOpenClose, "Exception O/C", "Opening-Exception / Closing-Exception", true},
+	451: {CIDOpenClose, "Early O/C", "Opening-Early / Closing-Early-User #", true},
+	452: {CIDOpenClose, "Late O/C", "Opening-Late / Closing-Late-User #", true},
+	453: {CIDOpenClose, "Failed to Open", "Trouble-Fail to open (Restore not applicable)", true},
+	454: {CIDOpenClose, "Failed to Close", "Trouble-Fail to Close (Restore not applicable)", true},
+	455: {CIDOpenClose, "Auto-Arm Failed", "Trouble-Auto Arm Failed (Restore not applicable)", true},
+	456: {CIDOpenClose, "Partial Arm", "Closing-Partial arm-User #", true},
+	457: {CIDOpenClose, "Exit Error (User)", "Closing-Exit Error-User #", true},
+	458: {CIDOpenClose, "User on Premises", "Opening-User on Prem. - User #", true},
+	459: {CIDOpenClose, "Recent Close", "Trouble-Recent Close - User # (Restore not applicable)", true},
+	461: {CIDOpenClose, "Wrong Code Entry", "Access - Wrong Code entry (Restore not applicable)", true},
+	462: {CIDOpenClose, "Legal Code Entry", "Acces-Legal Code entry - user # (Restore not applicable)", true},
+	463: {CIDOpenClose, "Re-arm after Alarm", "Status-Re Arm After Alarm-User # (restore not applicable)", true},
+	464: {CIDOpenClose, "Auto Arm Time Extended", "Status-Auto Arm Time Ext. - User # (Restore not applicable)", true},
+	465: {CIDOpenClose, "Panic Alarm Reset", "Status-PA Reset (Restore not applicable)", true},
+	466: {CIDOpenClose, "Service On/Off Premises", "Access Service on/off Prem - User #", true},
+	411: {CIDRemoteAccess, "CALLBACK REQUESTED", "Remote-Callback Requested (No Restore) Enabled with O/C reports", false},
+	412: {CIDRemoteAccess, "Success-Download/access", "Remote-Successful Access (Restore Not Applicable)", false},
+	413: {CIDRemoteAccess, "Unsuccessful Access", "Remote-Unsuccessful Access (Restore Not Applicable)", false},
+	414: {CIDRemoteAccess, "System Shutdown", "Remote-System Shutdown", false},
+	415: {CIDRemoteAccess, "Dialer Shutdown", "Remote-Dialer Shutdown", false},
+	416: {CIDRemoteAccess, "Successful Upload", "Remote-Successful Upload (Restore Not Applicable)", false},
+	421: {CIDAccessControl, "Access Denied", "Access-Access Denied-User # (Restore Not Applicable)", true},
+	422: {CIDAccessControl, "Access Report by User", "Access-Access Gained-User# (Restore Not Applicable)", true},
+	423: {CIDAccessControl, "Forced Access", "Panic-Forced Access-#", false},
+	424: {CIDAccessControl, "Egress Denied", "Access-Egress Denied (Restore Not Applicable)", false},
+	425: {CIDAccessControl, "Egress Granted", "Access-Egress Granted-# (Restore Not Applicable)", false},
+	426: {CIDAccessControl, "Access Door Propped Open", "Access-Door Propped Open-#", false},
+	427: {CIDAccessControl, "Access Point DSM Trouble", "Access-ACS Point DSM Trbl.-#", false},
+	428: {CIDAccessControl, "Access Point RTE Trouble", "Access-ACS Point RTE Trbl.-#", false},
+	429: {CIDAccessControl, "Access Program Mode Entry", "Access-ACS Prog. Entry-User # (Restore Not Applicable)", true},
+	430: {CIDAccessControl, "Access Program Mode Exit", "Access-ACS Prog. Exit-User # (Restore Not Applicable)", true},
+	431: {CIDAccessControl, "Access Threat Level Change", "Access-ACS Threat Level Chg.", false},
+	432: {CIDAccessControl, "Access Relay/Trigger Fail", "Access-ACS Relay/Trig. Fail-#", false},
+	433: {CIDAccessControl, "Access RTE Shunt", "Access-ACS RTE Shunt-#", false},
+	434: {CIDAccessControl, "Access DSM Shunt", "Access-ACS DSM Shunt-#", false},
+	501: {CIDDisable, "Access Reader Disable", "Disable-Access Rdr. Disable-#", false},
+	520: {CIDDisable, "Sounder/Relay Disable", "Disable-Sounder/Relay-#", false},
+	521: {CIDDisable, "Bell 1 Disable", "Disable-Bell/Siren # 1", false},
+	522: {CIDDisable, "Bell 2 Disable", "Disable-Bell/Siren # 2", false},
+	523: {CIDDisable, "Alarm Relay Disable", "Disable-Alarm Relay", false},
+	524: {CIDDisable, "Trouble Relay Disable", "Disable-Trouble Relay", false},
+	525: {CIDDisable, "Reversing Relay Disable", "Disable-Reversing Relay", false},
+	526: {CIDDisable, "Notification Appliance Ckt # 3", "Disable-Notification Appl. Ckt#3", false},
+	527: {CIDDisable, "Notification Appliance Ckt # 4", "Disable-Notification Appl. Ckt#4", false},
+	531: {CIDDisable, "Module Added", "Super.-Module Added (Restore Not Applicable)", false},
+	532: {CIDDisable, "Module Removed", "Super.-Module Removed (Restore Not Applicable)", false},
+	551: {CIDDisable, "Dialer Disabled", "Disable-Dialer Disable", false},
+	552: {CIDDisable, "Radio Xmitter Disabled", "Disable-Radio Disable", false},
+	553: {CIDDisable, "Remote Upload/Download", "Disable-Rem. Up/download Disable", false},
+	570: {CIDBypass, "ZONE/SENSOR BYPASS", "Bypass-Zone Bypass-#", false},
+	571: {CIDBypass, "Fire Bypass", "Bypass-Fire Bypass-#", false},
+	572: {CIDBypass, "24 Hour Zone Bypass", "Bypass-24 Hour Bypass-#", false},
+	573: {CIDBypass, "Burg. Bypass", "Bypass-Burg. Bypass-#", false},
+	574: {CIDBypass, "Group Bypass", "Bypass-Group Bypass-User #", true},
+	575: {CIDBypass, "SWINGER BYPASS", "Bypass-Swinger Bypass-#", false},
+	576: {CIDBypass, "Access Zone Shunt", "Access-ACS Zone Shunt-#", false},
+	577: {CIDBypass, "Access Point Bypass", "Access-ACS Point Bypass-#", false},
+	578: {CIDBypass, "Zone Bypass", "Bypass - Vault Bypass - #", false},
+	579: {CIDBypass, "Zone Bypass", "Bypass - Vent Zone Bypass - #", false},
+	601: {CIDTest, "MANUAL TEST", "Test-Manually Triggered (Restore Not Applicable)", false},
+	602: {CIDTest, "PERIODIC TEST", "Test-Periodic (Restore Not Applicable)", false},
+	603: {CIDTest, "Periodic RF Xmission", "Test-Periodic Radio (Restore Not Applicable)", false},
+	604: {CIDTest, "FIRE TEST", "Test-Fire Walk Test-User #", true},
+	605: {CIDTest, "Status Report To Follow", "Test-Fire Walk Test-User #", true},
+	606: {CIDTest, "LISTEN-IN TO FOLLOW", "Listen-Listen-In Active (Restore Not Applicable)", false},
+	607: {CIDTest, "WALK-TEST MODE", "Test-Walk Test Mode-User #", true},
+	608: {CIDTest, "System Trouble Present", "Test-System Trouble Present (Restore Not Applicable)", false},
+	609: {CIDTest, "VIDEO XMTR ACTIVE", "Listen-Video Xmitter Active (Restore Not Applicable)", false},
+	611: {CIDTest, "POINT TESTED OK", "Test-Point Tested OK-# (Restore Not Applicable)", false},
+	612: {CIDTest, "POINT NOT TESTED", "Test-Point Not Tested-# (Restore Not Applicable)", false},
+	613: {CIDTest, "Intrusion Zone Walk Tested", "Test-IntrnZone Walk Test-# (Restore Not Applicable)", false},
+	614: {CIDTest, "Fire Zone Walk Tested", "Test-Fire Zone Walk Test-# (Restore Not Applicable)", false},
+	615: {CIDTest, "Panic Zone Walk Tested", "Test-PA Zone Walk Test (Restore Not Applicable)", false},
+	616: {CIDTest, "Service Request", "Trouble-Service Request", false},
+	621: {CIDEventLog, "EVENT LOG RESET", "Trouble-Event Log Reset (Restore Not Applicable)", false},
+	622: {CIDEventLog, "EVENT LOG 50% FULL", "Trouble-Event Log 50% Full (Restore Not Applicable)", false},
+	623: {CIDEventLog, "EVENT LOG 90% FULL", "Trouble-Event Log 90% Full (Restore Not Applicable)", false},
+	624: {CIDEventLog, "EVENT LOG OVERFLOW", "Trouble-Event Log Overflow (Restore Not Applicable)", false},
+	625: {CIDEventLog, "TIME/DATE RESET", "Trouble-Time/Date Reset-User # (Restore Not Applicable)", true},
+	626: {CIDEventLog, "TIME/DATE INACCURATE", "Trouble-Time/Date Invalid (Clock not stamping to log correctly)", false},
+	627: {CIDEventLog, "PROGRAM MODE ENTRY", "Trouble-Program Mode Entry (Restore Not Applicable)", false},
+	628: {CIDEventLog, "PROGRAM MODE EXIT", "Trouble-Program Mode Exit (Restore Not Applicable)", false},
+	630: {CIDScheduling, "Schedule Change", "Trouble-Schedule Changed (Restore Not Applicable)", false},
+	631: {CIDScheduling, "Exception Sched. Change", "Trouble-Esc. Sched. Changed (Restore Not Applicable)", false},
+	632: {CIDScheduling, "Access Schedule Change", "Trouble-Access Sched. Changed (Restore Not Applicable)", false},
+	641: {CIDPersonnel, "Senior Watch Trouble", "Trouble-Senior Watch Trouble (\"This code is also refered to as 'up and about'. It means that a person has not moved about their home for a preset period of time\".)", false},
+	642: {CIDPersonnel, "Latch-key Supervision", "Status-Latch-key Super-User # (Restore Not Applicable)", true},
+	651: {CIDMiscellaneous, "ADT Dealer ID", "Code sent to Identify the control panel as an ADT Authorized Dealer.", false},
+	654: {CIDMiscellaneous, "System Inactivity", "Trouble - System Inactivity", false},
+	900: {CIDMiscellaneous, "Download Abort", "Remote - Download Abort (Restore not applicable)", false},
+	901: {CIDMiscellaneous, "Download Start/End", "Remote - Download Start - # / Remote - Download End - #", false},
+	902: {CIDMiscellaneous, "Download Interrupted", "Remote - Download Interrupt - #", false},
+	910: {CIDMiscellaneous, "Auto-Close with Bypass", "Closing - Auto Close - Bypass - #", false},
+	911: {CIDMiscellaneous, "Bypass Closing", "Closing - Bypass Closing - #", false},
+	912: {CIDMiscellaneous, "Fire Alarm Silenced", "Event", false},
+	913: {CIDMiscellaneous, "Supervisory Point test Start/End", "Event - User-#", true},
+	914: {CIDMiscellaneous, "Hold-up test Start/End", "Event - User-#", true},
+	915: {CIDMiscellaneous, "Burg. Test Print Start/End", "Event", false},
+	916: {CIDMiscellaneous, "Supervisory Test Print Start/End", "Event", false},
+	917: {CIDMiscellaneous, "Burg. Diagnostics Start/End", "Event", false},
+	918: {CIDMiscellaneous, "Fire Diagnostics Start/End", "Event", false},
+	919: {CIDMiscellaneous, "Untyped diagnostics", "Event", false},
+	920: {CIDMiscellaneous, "Trouble Closing", "Trouble Closing (closed with burg. during exit)", false},
+	921: {CIDMiscellaneous, "Access Denied Code Unknown", "Event", false},
+	922: {CIDMiscellaneous, "Supervisory Point Alarm", "Alarm - Zone #", false},
+	923: {CIDMiscellaneous, "Supervisory Point Bypass", "Event - Zone #", false},
+	924: {CIDMiscellaneous, "Supervisory Point Trouble", "Trouble Zone #", false},
+	925: {CIDMiscellaneous, "Hold-up Point Bypass", "Event - Zone #", false},
+	926: {CIDMiscellaneous, "AC Failure for 4 hours", "Event", false},
+	927: {CIDMiscellaneous, "Output Trouble", "Trouble", false},
+	928: {CIDMiscellaneous, "User code for event", "Event", false},
+	929: {CIDMiscellaneous, "Log-off", "Event", false},
+	954: {CIDMiscellaneous, "CS Connection Failure", "Event", false},
+	961: {CIDMiscellaneous, "Rcvr Database Connection Fail/Restore", "", false},
+	962: {CIDMiscellaneous, "License Expiration Notify", "Event", false},
+	999: {CIDMiscellaneous, "LOG EVENT ONLY", "1 and 1/3 DAY NO READ LOG EVENT LOG ONLY, No report to CS.", false},
+}
@@ -0,0 +1,166 @@
+package tpi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_RealtimeCID(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            string
+		wantQualifier   CIDQualifier
+		wantCode        int
+		wantCategory    CIDCategory
+		wantDescription string
+		wantPartition   int
+		wantZoneOrUser  int
+		wantIsUser      bool
+		wantErr         bool
+	}{
+		{
+			name:            "armed stay restoral by user 2 (doc example)",
+			data:            "3441010020",
+			wantQualifier:   CIDRestore,
+			wantCode:        441,
+			wantCategory:    CIDOpenClose,
+			wantDescription: "Armed Stay",
+			wantPartition:   1,
+			wantZoneOrUser:  2,
+			wantIsUser:      true,
+		},
+		{
+			name:            "burglary perimeter zone 12",
+			data:            "1131010120",
+			wantQualifier:   CIDEvent,
+			wantCode:        131,
+			wantCategory:    CIDBurglar,
+			wantDescription: "PERIMETER",
+			wantPartition:   1,
+			wantZoneOrUser:  12,
+		},
+		{
+			name:            "AC loss",
+			data:            "1301000000",
+			wantQualifier:   CIDEvent,
+			wantCode:        301,
+			wantCategory:    CIDTrouble,
+			wantDescription: "AC LOSS",
+		},
+		{
+			name:            "fire on partition 2",
+			data:            "1110020050",
+			wantQualifier:   CIDEvent,
+			wantCode:        110,
+			wantCategory:    CIDFire,
+			wantDescription: "FIRE",
+			wantPartition:   2,
+			wantZoneOrUser:  5,
+		},
+		{
+			name:            "zone bypass",
+			data:            "1570010030",
+			wantQualifier:   CIDEvent,
+			wantCode:        570,
+			wantCategory:    CIDBypass,
+			wantDescription: "ZONE/SENSOR BYPASS",
+			wantPartition:   1,
+			wantZoneOrUser:  3,
+		},
+		{
+			name:            "protection one range",
+			data:            "1760010000",
+			wantQualifier:   CIDEvent,
+			wantCode:        760,
+			wantCategory:    CIDMiscellaneous,
+			wantDescription: "Protection One Use",
+			wantPartition:   1,
+		},
+		{
+			name:            "unknown code",
+			data:            "1998010000",
+			wantQualifier:   CIDEvent,
+			wantCode:        998,
+			wantCategory:    CIDUnknown,
+			wantDescription: "Unknown CID code 998",
+			wantPartition:   1,
+		},
+		{
+			name:    "too short",
+			data:    "34410100",
+			wantErr: true,
+		},
+		{
+			name:    "not decimal",
+			data:    "3441A10020",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse("%03," + tt.data + "$")
+			if tt.wantErr {
+				if _, ok := err.(*ParseError); !ok {
+					t.Fatalf("Parse() error = %v, want *ParseError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() returned unexpected error: %v", err)
+			}
+
+			cid, ok := p.(RealtimeCID)
+			if !ok {
+				t.Fatalf("Parse() type = %T, want RealtimeCID", p)
+			}
+			if cid.Qualifier != tt.wantQualifier {
+				t.Errorf("Qualifier = %v, want %v", cid.Qualifier, tt.wantQualifier)
+			}
+			if cid.Code != tt.wantCode {
+				t.Errorf("Code = %d, want %d", cid.Code, tt.wantCode)
+			}
+			if cid.Category != tt.wantCategory {
+				t.Errorf("Category = %q, want %q", cid.Category, tt.wantCategory)
+			}
+			if cid.Description != tt.wantDescription {
+				t.Errorf("Description = %q, want %q", cid.Description, tt.wantDescription)
+			}
+			if cid.Partition != tt.wantPartition {
+				t.Errorf("Partition = %d, want %d", cid.Partition, tt.wantPartition)
+			}
+			if cid.ZoneOrUser != tt.wantZoneOrUser {
+				t.Errorf("ZoneOrUser = %d, want %d", cid.ZoneOrUser, tt.wantZoneOrUser)
+			}
+			if cid.IsUser != tt.wantIsUser {
+				t.Errorf("IsUser = %v, want %v", cid.IsUser, tt.wantIsUser)
+			}
+		})
+	}
+}
+
+func TestRealtimeCID_String(t *testing.T) {
+	p, err := Parse("%03,3441010020$")
+	if err != nil {
+		t.Fatalf("Parse() returned unexpected error: %v", err)
+	}
+	want := "restore 441 Armed Stay [Open/Close] partition 1 user 2"
+	if got := p.(RealtimeCID).String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestClient_ReadLoop_LogsCID(t *testing.T) {
+	_, tpiLogger := newTestLogger()
+	appBuf, appLogger := newTestLogger()
+
+	client := NewClient("192.168.1.50:4025", "testpass", tpiLogger, appLogger, -1)
+	client.conn = newMockConn("%03,1131010120$\n")
+
+	_ = client.ReadLoop()
+
+	want := "INFO: CID event 131 PERIMETER [Burglar] partition 1 zone 12"
+	if appOutput := appBuf.String(); !strings.Contains(appOutput, want) {
+		t.Errorf("app log should contain %q, got %q", want, appOutput)
+	}
+}
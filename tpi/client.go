@@ -2,10 +2,13 @@ package tpi
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -14,68 +17,152 @@ const (
 	maxDelay     = 60 * time.Second
 	multiplier   = 2.0
 	authTimeout  = 10 * time.Second
+	writeTimeout = 5 * time.Second
+	ackTimeout   = 5 * time.Second
+	connTimeout  = 10 * time.Second
 )
 
-// dialTimeout is a variable to allow mocking in tests
-var dialTimeout = net.DialTimeout
+// DefaultIdleTimeout is how long ReadLoop tolerates silence before reconnecting
+const DefaultIdleTimeout = 60 * time.Second
+
+// dialContext is a variable to allow mocking in tests
+var dialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+	d := net.Dialer{Timeout: connTimeout}
+	return d.DialContext(ctx, network, address)
+}
 
 // Client manages the TPI connection and message handling
 type Client struct {
-	address          string
-	password         string
-	conn             net.Conn
-	tpiLogger        *log.Logger
-	appLogger        *log.Logger
-	stopCh           chan struct{}
-	reconnectDelay   time.Duration
-	deduplicateLimit int // -1: disabled, 0: infinite, >0: ignore n duplicates
-	deduplicateCount int
-	lastMessage      string
+	address           string
+	password          string
+	conn              net.Conn
+	writeMu           sync.Mutex // Serializes writes and guards conn swaps
+	cmdMu             sync.Mutex // Allows one command in flight, as the TPI requires
+	pending           commandTracker
+	ackTimeout        time.Duration
+	keepaliveInterval time.Duration
+	subscribers       subscribers
+	status            connStatus
+	idleTimeout       time.Duration
+	tpiLogger         *log.Logger
+	appLogger         *log.Logger
+	stopCh            chan struct{}
+	closeOnce         sync.Once
+	reconnectDelay    time.Duration
+	deduplicateLimit  int // -1: disabled, 0: infinite, >0: ignore n duplicates
+	deduplicateCount  int
+	lastMessage       string
+	zones             *ZoneStateChange      // Last zone state seen, nil until the first %01
+	partitions        *PartitionStateChange // Last partition state seen, nil until the first %02
 }
 
 // NewClient creates a new TPI client
 func NewClient(address, password string, tpiLogger, appLogger *log.Logger, deduplicateLimit int) *Client {
 	return &Client{
-		address:          address,
-		password:         password,
-		tpiLogger:        tpiLogger,
-		appLogger:        appLogger,
-		stopCh:           make(chan struct{}),
-		reconnectDelay:   initialDelay,
-		deduplicateLimit: deduplicateLimit,
-		deduplicateCount: 0,
-		lastMessage:      "",
+		address:           address,
+		password:          password,
+		tpiLogger:         tpiLogger,
+		appLogger:         appLogger,
+		stopCh:            make(chan struct{}),
+		reconnectDelay:    initialDelay,
+		ackTimeout:        ackTimeout,
+		keepaliveInterval: DefaultKeepaliveInterval,
+		idleTimeout:       DefaultIdleTimeout,
+		deduplicateLimit:  deduplicateLimit,
+		deduplicateCount:  0,
+		lastMessage:       "",
+	}
+}
+
+// Run connects, reads and reconnects with backoff until ctx is cancelled or
+// Close is called. It returns the context's error once shut down.
+func (c *Client) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Close stops Run the same way cancelling ctx does
+	go func() {
+		select {
+		case <-c.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		if err := c.ConnectContext(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Connection or auth failed, will retry with backoff
+			continue
+		}
+
+		// ReadLoopContext runs until error, disconnect or cancellation
+		err := c.ReadLoopContext(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c.appLogger.Printf("WARN: Connection lost: %v", err)
 	}
 }
 
 // Connect establishes a TCP connection to the TPI server and authenticates
 func (c *Client) Connect() error {
-	c.reconnectWithBackoff()
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext is Connect with cancellation. Cancelling ctx interrupts the
+// backoff wait, dialing and authentication, and returns ctx.Err().
+func (c *Client) ConnectContext(ctx context.Context) error {
+	if err := c.reconnectWithBackoff(ctx); err != nil {
+		return err
+	}
 
 	// Establish TCP connection
 	c.appLogger.Printf("INFO: Connecting to %s", c.address)
-	conn, err := dialTimeout("tcp", c.address, 10*time.Second)
+	conn, err := dialContext(ctx, "tcp", c.address)
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		c.appLogger.Printf("ERROR: Failed to connect: %v", err)
 		return &ConnectionError{Message: "failed to dial", Err: err}
 	}
 
-	c.conn = conn
+	c.setConn(conn)
 	c.appLogger.Printf("INFO: Connected to %s", c.address)
 
-	// Authenticate
-	if err := c.authenticate(); err != nil {
-		c.conn.Close()
-		c.conn = nil
+	// Authenticate, aborting the blocked read if ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	err = c.authenticate()
+	stop()
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		c.setConn(nil)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return err
 	}
 
 	c.appLogger.Println("INFO: Authentication successful")
 	c.resetBackoff()
+	c.markAuthenticated(time.Now())
 
 	return nil
 }
 
+// setConn swaps the active connection without racing an in-flight command
+func (c *Client) setConn(conn net.Conn) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn = conn
+}
+
 // authenticate handles the login flow
 func (c *Client) authenticate() error {
 	// Set timeout for authentication phase
@@ -125,10 +212,43 @@ func (c *Client) authenticate() error {
 
 // ReadLoop reads messages from the TPI server and logs them
 func (c *Client) ReadLoop() error {
-	scanner := bufio.NewScanner(c.conn)
+	return c.ReadLoopContext(context.Background())
+}
+
+// ReadLoopContext is ReadLoop with cancellation. Cancelling ctx closes the
+// connection, which unblocks the read, and returns ctx.Err().
+func (c *Client) ReadLoopContext(ctx context.Context) error {
+	conn := c.conn
+	if conn == nil {
+		return &ConnectionError{Message: "not connected"}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
 
-	for scanner.Scan() {
+	// Keep the Envisalink's network watchdog fed while connected
+	stopKeepalive := c.startKeepalive()
+	defer stopKeepalive()
+
+	scanner := bufio.NewScanner(conn)
+
+	for {
+		// Keypad updates arrive every 5-10 seconds, so prolonged silence means a dead link
+		if c.idleTimeout > 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(c.idleTimeout)); err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				return c.endReadLoop(&ConnectionError{Message: "failed to set read deadline", Err: err})
+			}
+		}
+		if !scanner.Scan() {
+			break
+		}
 		line := scanner.Text()
+		received := time.Now()
+
+		// Decode before deduplication so repeated acks still reach their commands
+		c.handleLine(line, received)
 
 		if line == c.lastMessage {
 			if c.deduplicateLimit == 0 {
@@ -153,34 +273,124 @@ func (c *Client) ReadLoop() error {
 		c.tpiLogger.Println(line)
 	}
 
+	if ctx.Err() != nil {
+		c.appLogger.Println("INFO: Read loop stopped")
+		return c.endReadLoop(ctx.Err())
+	}
+
 	// Check for errors
 	if err := scanner.Err(); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			c.appLogger.Printf("WARN: No data received for %v, assuming connection is dead", c.idleTimeout)
+			return c.endReadLoop(&TimeoutError{Operation: "read", Err: err})
+		}
 		c.appLogger.Printf("WARN: Read error: %v", err)
-		return &ConnectionError{Message: "read error", Err: err}
+		return c.endReadLoop(&ConnectionError{Message: "read error", Err: err})
 	}
 
 	// EOF reached (connection closed)
 	c.appLogger.Println("WARN: Connection closed by remote")
-	return &ConnectionError{Message: "connection closed", Err: nil}
+	return c.endReadLoop(&ConnectionError{Message: "connection closed", Err: nil})
 }
 
-// Close gracefully closes the connection
-func (c *Client) Close() error {
-	close(c.stopCh)
+// endReadLoop fails any commands still waiting for an ack and drops the
+// connection so the next Connect starts from a clean socket
+func (c *Client) endReadLoop(err error) error {
+	c.pending.failAll(err)
+	c.markDisconnected()
 
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
 	if c.conn != nil {
-		c.appLogger.Println("INFO: Closing connection")
-		return c.conn.Close()
+		c.conn.Close()
+		c.conn = nil
 	}
+	return err
+}
 
-	return nil
+// SetIdleTimeout sets how long ReadLoop waits without receiving anything before
+// treating the link as dead and returning a *TimeoutError. Zero disables it.
+func (c *Client) SetIdleTimeout(d time.Duration) {
+	c.idleTimeout = d
+}
+
+// handleLine decodes a received line, publishes it to subscribers and acts on
+// packets that carry state
+func (c *Client) handleLine(line string, received time.Time) {
+	c.markReceived(received)
+	packet, err := Parse(line)
+	c.publish(Message{Time: received, Raw: line, Packet: packet, Err: err})
+	if err != nil {
+		c.appLogger.Printf("DEBUG: Ignoring line: %v", err)
+		return
+	}
+
+	switch p := packet.(type) {
+	case ZoneStateChange:
+		c.handleZoneStateChange(p)
+	case PartitionStateChange:
+		c.handlePartitionStateChange(p)
+	case RealtimeCID:
+		c.appLogger.Printf("INFO: CID %s", p)
+	case CommandAck:
+		if !c.pending.resolve(p) {
+			c.appLogger.Printf("WARN: Unexpected ack for command %s: %s", p.Code, p.Data)
+		}
+	}
+}
+
+// handleZoneStateChange logs per-zone transitions against the previous zone state.
+// The first update only establishes the baseline.
+func (c *Client) handleZoneStateChange(p ZoneStateChange) {
+	if c.zones != nil {
+		for _, t := range p.Diff(*c.zones) {
+			c.appLogger.Printf("INFO: Zone transition: %s", t)
+		}
+	}
+	c.zones = &p
+}
+
+// handlePartitionStateChange logs partition transitions against the previous state.
+// The first update only establishes the baseline.
+func (c *Client) handlePartitionStateChange(p PartitionStateChange) {
+	if c.partitions != nil {
+		for _, t := range p.Diff(*c.partitions) {
+			c.appLogger.Printf("INFO: Partition transition: %s", t)
+		}
+	}
+	c.partitions = &p
+}
+
+// Close gracefully closes the connection and stops Run. It is safe to call more than once.
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stopCh)
+		c.closeSubscribers()
+
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		if c.conn != nil {
+			c.appLogger.Println("INFO: Closing connection")
+			err = c.conn.Close()
+		}
+	})
+	return err
 }
 
-// reconnectWithBackoff implements exponential backoff for reconnection
-func (c *Client) reconnectWithBackoff() {
+// reconnectWithBackoff implements exponential backoff for reconnection.
+// It returns ctx.Err() if cancelled while waiting.
+func (c *Client) reconnectWithBackoff(ctx context.Context) error {
 	if c.reconnectDelay > initialDelay {
 		c.appLogger.Printf("INFO: Waiting %v before reconnecting...", c.reconnectDelay)
-		time.Sleep(c.reconnectDelay)
+		timer := time.NewTimer(c.reconnectDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
 	}
 
 	// Increase delay for next time
@@ -188,6 +398,7 @@ func (c *Client) reconnectWithBackoff() {
 	if c.reconnectDelay > maxDelay {
 		c.reconnectDelay = maxDelay
 	}
+	return nil
 }
 
 // resetBackoff resets the reconnection delay after successful connection
@@ -1,6 +1,8 @@
 package tpi
 
 import (
+	"context"
+	"errors"
 	"io"
 	"log"
 	"net"
@@ -424,8 +426,8 @@ func TestClient_authenticate(t *testing.T) {
 
 func TestClient_Connect(t *testing.T) {
 	// Save original dialer and restore after test
-	originalDial := dialTimeout
-	defer func() { dialTimeout = originalDial }()
+	originalDial := dialContext
+	defer func() { dialContext = originalDial }()
 
 	tests := []struct {
 		name        string
@@ -462,8 +464,8 @@ func TestClient_Connect(t *testing.T) {
 				-1,
 			)
 
-			// Mock dialTimeout
-			dialTimeout = func(network, address string, timeout time.Duration) (net.Conn, error) {
+			// Mock dialContext
+			dialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
 				if tt.dialErr != nil {
 					return nil, tt.dialErr
 				}
@@ -502,4 +504,136 @@ func TestClient_Connect(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestClient_ReadLoop_IdleTimeout(t *testing.T) {
+	_, tpiLogger := newTestLogger()
+	appBuf, appLogger := newTestLogger()
+
+	client := NewClient("192.168.1.50:4025", "testpass", tpiLogger, appLogger, -1)
+	client.SetIdleTimeout(50 * time.Millisecond)
+
+	server, conn := net.Pipe()
+	defer server.Close()
+	client.conn = conn
+
+	// One keypad update then silence
+	go server.Write([]byte("%00,01,1C08,08,00,****DISARMED****  Ready to Arm  $\r\n"))
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- client.ReadLoop() }()
+
+	select {
+	case err := <-errCh:
+		if _, ok := err.(*TimeoutError); !ok {
+			t.Errorf("ReadLoop() error = %v (%T), want *TimeoutError", err, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ReadLoop() did not return after the idle timeout")
+	}
+
+	if !strings.Contains(appBuf.String(), "assuming connection is dead") {
+		t.Errorf("app log should report the dead link, got %q", appBuf.String())
+	}
+	if client.conn != nil {
+		t.Error("client.conn should be dropped after the idle timeout")
+	}
+}
+
+func TestClient_ConnectContext_CancelBackoff(t *testing.T) {
+	client := newTestClient(-1)
+	client.reconnectDelay = maxDelay
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := client.ConnectContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ConnectContext() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ConnectContext() took %v, backoff wait was not interrupted", elapsed)
+	}
+}
+
+func TestClient_ConnectContext_CancelAuth(t *testing.T) {
+	originalDial := dialContext
+	defer func() { dialContext = originalDial }()
+
+	server, conn := net.Pipe()
+	defer server.Close()
+	dialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return conn, nil // The server never sends a login prompt
+	}
+
+	client := newTestClient(-1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := client.ConnectContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ConnectContext() error = %v, want context.DeadlineExceeded", err)
+	}
+	if client.conn != nil {
+		t.Error("client.conn should be nil after cancelled Connect")
+	}
+}
+
+func TestClient_ReadLoopContext_Cancel(t *testing.T) {
+	client := newTestClient(-1)
+	server, conn := net.Pipe()
+	defer server.Close()
+	client.conn = conn
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- client.ReadLoopContext(ctx) }()
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ReadLoopContext() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReadLoopContext() did not return after cancellation")
+	}
+}
+
+func TestClient_Run_StopsOnClose(t *testing.T) {
+	originalDial := dialContext
+	defer func() { dialContext = originalDial }()
+
+	dialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		server, conn := net.Pipe()
+		go func() {
+			server.Write([]byte("Login:\r\n"))
+			buf := make([]byte, 64)
+			server.Read(buf)
+			server.Write([]byte("OK\r\n"))
+			io.Copy(io.Discard, server)
+		}()
+		return conn, nil
+	}
+
+	client := newTestClient(-1)
+	errCh := make(chan error, 1)
+	go func() { errCh <- client.Run(context.Background()) }()
+
+	time.Sleep(50 * time.Millisecond)
+	client.Close()
+	client.Close() // Safe to call twice
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after Close")
+	}
+}
@@ -0,0 +1,122 @@
+package tpi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Application command codes sent to the Envisalink (section 3.2)
+const (
+	CmdPoll                   = "00"
+	CmdChangeDefaultPartition = "01"
+	CmdDumpZoneTimers         = "02"
+	CmdKeypress               = "03"
+)
+
+// validKeys are the keystrokes accepted by the Keypress command
+const validKeys = "0123456789ABCD*#"
+
+// Poll sends a POLL, which also resets the Envisalink's network watchdog
+func (c *Client) Poll() error {
+	return c.sendCommand(CmdPoll, "")
+}
+
+// ChangeDefaultPartition changes which partition virtual keypad keystrokes go to
+func (c *Client) ChangeDefaultPartition(partition int) error {
+	if err := validatePartition(partition); err != nil {
+		return err
+	}
+	return c.sendCommand(CmdChangeDefaultPartition, strconv.Itoa(partition))
+}
+
+// DumpZoneTimers asks the Envisalink to send a Zone Timer Dump (%FF)
+func (c *Client) DumpZoneTimers() error {
+	return c.sendCommand(CmdDumpZoneTimers, "")
+}
+
+// Keypress sends keystrokes to a specific partition. Each key is sent as its
+// own ^03 command since the TPI accepts a single key per packet. If one fails
+// the rest are not sent and a *KeypressError says how many were.
+func (c *Client) Keypress(partition int, keys string) error {
+	if err := validatePartition(partition); err != nil {
+		return err
+	}
+	if keys == "" {
+		return fmt.Errorf("no keys to send")
+	}
+	keys = strings.ToUpper(keys)
+	for _, k := range keys {
+		if !strings.ContainsRune(validKeys, k) {
+			return fmt.Errorf("invalid key %q, must be one of %s", k, validKeys)
+		}
+	}
+
+	for i, k := range keys {
+		if err := c.sendCommand(CmdKeypress, fmt.Sprintf("%d,%c", partition, k)); err != nil {
+			return &KeypressError{Partition: partition, Sent: i, Total: len(keys), Err: err}
+		}
+	}
+	return nil
+}
+
+// sendCommand writes a command and waits for the Envisalink to acknowledge it.
+// A non-zero response code is returned as a *CommandError and a missing ack as a
+// *TimeoutError. Commands are sent one at a time; ReadLoop must be running to
+// receive the ack.
+func (c *Client) sendCommand(code, data string) error {
+	c.cmdMu.Lock()
+	defer c.cmdMu.Unlock()
+
+	p := c.pending.add(code)
+	if err := c.writeCommand(code, data); err != nil {
+		c.pending.remove(p)
+		return err
+	}
+
+	timer := time.NewTimer(c.ackTimeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-p.done:
+		if res.err != nil {
+			return res.err
+		}
+		if res.ack.Result != ResponseOK {
+			return &CommandError{Command: code, Code: res.ack.Result}
+		}
+		return nil
+	case <-timer.C:
+		c.pending.remove(p)
+		return &TimeoutError{Operation: fmt.Sprintf("ack for command %s", code), Err: fmt.Errorf("no response after %v", c.ackTimeout)}
+	}
+}
+
+// writeCommand frames and writes ^CC,DATA$. The comma is mandatory even without data.
+func (c *Client) writeCommand(code, data string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.conn == nil {
+		return &ConnectionError{Message: "not connected"}
+	}
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return &TimeoutError{Operation: "set write deadline", Err: err}
+	}
+	defer c.conn.SetWriteDeadline(time.Time{})
+
+	if _, err := fmt.Fprintf(c.conn, "^%s,%s$", code, data); err != nil {
+		return &ConnectionError{Message: fmt.Sprintf("failed to send command %s", code), Err: err}
+	}
+	return nil
+}
+
+// validatePartition checks a partition number is within 1-8
+func validatePartition(partition int) error {
+	if partition < 1 || partition > MaxPartitions {
+		return fmt.Errorf("partition must be between 1 and %d, got: %d", MaxPartitions, partition)
+	}
+	return nil
+}
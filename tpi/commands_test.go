@@ -0,0 +1,212 @@
+package tpi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Commands(t *testing.T) {
+	tests := []struct {
+		name     string
+		send     func(c *Client) error
+		wantSent []string
+		wantErr  bool
+	}{
+		{
+			name:     "poll",
+			send:     func(c *Client) error { return c.Poll() },
+			wantSent: []string{"^00,$"},
+		},
+		{
+			name:     "change default partition",
+			send:     func(c *Client) error { return c.ChangeDefaultPartition(2) },
+			wantSent: []string{"^01,2$"},
+		},
+		{
+			name:     "dump zone timers",
+			send:     func(c *Client) error { return c.DumpZoneTimers() },
+			wantSent: []string{"^02,$"},
+		},
+		{
+			name:     "keypress sends one packet per key",
+			send:     func(c *Client) error { return c.Keypress(1, "12*#") },
+			wantSent: []string{"^03,1,1$", "^03,1,2$", "^03,1,*$", "^03,1,#$"},
+		},
+		{
+			name:     "keypress function keys are upper cased",
+			send:     func(c *Client) error { return c.Keypress(3, "a") },
+			wantSent: []string{"^03,3,A$"},
+		},
+		{
+			name:    "invalid partition",
+			send:    func(c *Client) error { return c.ChangeDefaultPartition(9) },
+			wantErr: true,
+		},
+		{
+			name:    "invalid key",
+			send:    func(c *Client) error { return c.Keypress(1, "12X") },
+			wantErr: true,
+		},
+		{
+			name:    "no keys",
+			send:    func(c *Client) error { return c.Keypress(1, "") },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(-1)
+			received := newFakePanel(t, client, ackWith("00"))
+
+			err := tt.send(client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var sent []string
+			for len(sent) < len(tt.wantSent) {
+				sent = append(sent, <-received)
+			}
+			if strings.Join(sent, "") != strings.Join(tt.wantSent, "") {
+				t.Errorf("sent %q, want %q", sent, tt.wantSent)
+			}
+			select {
+			case extra := <-received:
+				t.Errorf("unexpected extra command %q", extra)
+			default:
+			}
+		})
+	}
+}
+
+func TestClient_Commands_NotConnected(t *testing.T) {
+	client := newTestClient(-1)
+
+	err := client.Poll()
+	if _, ok := err.(*ConnectionError); !ok {
+		t.Errorf("Poll() error = %T, want *ConnectionError", err)
+	}
+}
+
+func TestClient_Keypress_PartialFailure(t *testing.T) {
+	client := newTestClient(-1)
+	sent := 0
+	newFakePanel(t, client, func(cmd string) string {
+		sent++
+		if sent == 3 {
+			return "^03,03$"
+		}
+		return "^03,00$"
+	})
+
+	err := client.Keypress(1, "25801")
+	keyErr, ok := err.(*KeypressError)
+	if !ok {
+		t.Fatalf("Keypress() error = %v (%T), want *KeypressError", err, err)
+	}
+	if keyErr.Sent != 2 || keyErr.Total != 5 {
+		t.Errorf("KeypressError = %+v, want 2 of 5 keys sent", keyErr)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != ResponseSyntaxError {
+		t.Errorf("Keypress() error = %v, want it to wrap the rejection", err)
+	}
+	if strings.Contains(err.Error(), "2580") {
+		t.Errorf("Keypress() error %q contains the keys", err)
+	}
+}
+
+func TestClient_Commands_ResponseCodes(t *testing.T) {
+	tests := []struct {
+		result   string
+		wantCode int
+	}{
+		{result: "01", wantCode: ResponseBufferOverrun},
+		{result: "02", wantCode: ResponseUnknownCommand},
+		{result: "03", wantCode: ResponseSyntaxError},
+		{result: "04", wantCode: ResponseBufferOverflow},
+		{result: "05", wantCode: ResponseStateMachineTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.result, func(t *testing.T) {
+			client := newTestClient(-1)
+			newFakePanel(t, client, ackWith(tt.result))
+
+			err := client.Poll()
+			cmdErr, ok := err.(*CommandError)
+			if !ok {
+				t.Fatalf("Poll() error = %v (%T), want *CommandError", err, err)
+			}
+			if cmdErr.Command != CmdPoll || cmdErr.Code != tt.wantCode {
+				t.Errorf("CommandError = %+v, want command %s code %d", cmdErr, CmdPoll, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestClient_Commands_AckTimeout(t *testing.T) {
+	client := newTestClient(-1)
+	client.ackTimeout = 50 * time.Millisecond
+	newFakePanel(t, client, func(string) string { return "" })
+
+	err := client.Poll()
+	if _, ok := err.(*TimeoutError); !ok {
+		t.Fatalf("Poll() error = %v (%T), want *TimeoutError", err, err)
+	}
+	if len(client.pending.pending) != 0 {
+		t.Errorf("pending commands = %d after timeout, want 0", len(client.pending.pending))
+	}
+}
+
+func TestClient_Commands_ConnectionLost(t *testing.T) {
+	client := newTestClient(-1)
+	received := newFakePanel(t, client, func(string) string { return "" })
+	conn := client.conn
+
+	go func() {
+		<-received
+		conn.Close()
+	}()
+
+	err := client.Poll()
+	if _, ok := err.(*ConnectionError); !ok {
+		t.Fatalf("Poll() error = %v (%T), want *ConnectionError", err, err)
+	}
+}
+
+func TestCommandTracker(t *testing.T) {
+	var tracker commandTracker
+	poll := tracker.add(CmdPoll)
+	dump := tracker.add(CmdDumpZoneTimers)
+
+	if tracker.resolve(CommandAck{Frame: Frame{Code: CmdKeypress}}) {
+		t.Error("resolve() matched an ack with no pending command")
+	}
+	if !tracker.resolve(CommandAck{Frame: Frame{Code: CmdDumpZoneTimers}, Result: 3}) {
+		t.Fatal("resolve() did not match pending dump command")
+	}
+	if res := <-dump.done; res.ack.Result != 3 {
+		t.Errorf("dump result = %d, want 3", res.ack.Result)
+	}
+
+	tracker.failAll(&ConnectionError{Message: "connection closed"})
+	if res := <-poll.done; res.err == nil {
+		t.Error("failAll() did not deliver an error to the pending poll")
+	}
+}
+
+func TestClient_Commands_DeduplicatedAcks(t *testing.T) {
+	// Identical acks must still resolve commands when deduplication hides them from the log
+	client := newTestClient(0)
+	newFakePanel(t, client, ackWith("00"))
+
+	for i := 0; i < 3; i++ {
+		if err := client.Poll(); err != nil {
+			t.Fatalf("Poll() #%d error = %v", i+1, err)
+		}
+	}
+}
@@ -33,3 +33,64 @@ type TimeoutError struct {
 func (e *TimeoutError) Error() string {
 	return fmt.Sprintf("timeout during %s: %v", e.Operation, e.Err)
 }
+
+// ParseError represents a line that is not a valid TPI frame or payload
+type ParseError struct {
+	Line   string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid TPI packet %q: %s", e.Line, e.Reason)
+}
+
+// TPI response codes returned in a command acknowledgement (section 3.7)
+const (
+	ResponseOK                  = 0
+	ResponseBufferOverrun       = 1
+	ResponseUnknownCommand      = 2
+	ResponseSyntaxError         = 3
+	ResponseBufferOverflow      = 4
+	ResponseStateMachineTimeout = 5
+)
+
+var responseDescriptions = map[int]string{
+	ResponseOK:                  "no error",
+	ResponseBufferOverrun:       "receive buffer overrun",
+	ResponseUnknownCommand:      "unknown command",
+	ResponseSyntaxError:         "syntax error",
+	ResponseBufferOverflow:      "receive buffer overflow",
+	ResponseStateMachineTimeout: "receive state machine timeout",
+}
+
+// CommandError represents a command rejected by the Envisalink with a non-zero response code
+type CommandError struct {
+	Command string
+	Code    int
+}
+
+func (e *CommandError) Error() string {
+	desc, ok := responseDescriptions[e.Code]
+	if !ok {
+		desc = "unknown response code"
+	}
+	return fmt.Sprintf("command %s rejected: %s (code %d)", e.Command, desc, e.Code)
+}
+
+// KeypressError reports a keypress sequence that failed part way. The keys
+// already sent may be waiting on the panel as a partial entry. The keys
+// themselves are left out since they usually contain a user code.
+type KeypressError struct {
+	Partition int
+	Sent      int // Keys acknowledged before the failure
+	Total     int
+	Err       error
+}
+
+func (e *KeypressError) Error() string {
+	return fmt.Sprintf("keypress on partition %d failed after %d of %d keys: %v", e.Partition, e.Sent, e.Total, e.Err)
+}
+
+func (e *KeypressError) Unwrap() error {
+	return e.Err
+}
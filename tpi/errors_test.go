@@ -123,3 +123,32 @@ func TestTimeoutError_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestParseError_Error(t *testing.T) {
+	e := &ParseError{Line: "Login:", Reason: "unexpected start sentinel 'L'"}
+	want := `invalid TPI packet "Login:": unexpected start sentinel 'L'`
+	if got := e.Error(); got != want {
+		t.Errorf("ParseError.Error() = %q, want %q", got, want)
+	}
+}
+
+func TestCommandError_Error(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{code: ResponseBufferOverrun, want: "command 00 rejected: receive buffer overrun (code 1)"},
+		{code: ResponseSyntaxError, want: "command 00 rejected: syntax error (code 3)"},
+		{code: ResponseStateMachineTimeout, want: "command 00 rejected: receive state machine timeout (code 5)"},
+		{code: 9, want: "command 00 rejected: unknown response code (code 9)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			e := &CommandError{Command: "00", Code: tt.code}
+			if got := e.Error(); got != tt.want {
+				t.Errorf("CommandError.Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
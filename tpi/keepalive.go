@@ -0,0 +1,50 @@
+package tpi
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultKeepaliveInterval keeps well inside the Envisalink's 20 minute network watchdog
+const DefaultKeepaliveInterval = 5 * time.Minute
+
+// SetKeepaliveInterval sets how often a POLL is sent while connected. The POLL
+// resets the Envisalink's network watchdog, which otherwise reboots the module
+// after 20 minutes without contact with the Envisalerts servers. Zero disables it.
+func (c *Client) SetKeepaliveInterval(d time.Duration) {
+	c.keepaliveInterval = d
+}
+
+// startKeepalive polls the Envisalink on the keepalive interval until the
+// returned stop function is called
+func (c *Client) startKeepalive() (stop func()) {
+	if c.keepaliveInterval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(c.keepaliveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := c.Poll(); err != nil {
+					c.appLogger.Printf("WARN: Keepalive poll failed: %v", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,52 @@
+package tpi
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Keepalive(t *testing.T) {
+	client := newTestClient(-1)
+	client.SetKeepaliveInterval(10 * time.Millisecond)
+	received := newFakePanel(t, client, ackWith("00"))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case cmd := <-received:
+			if cmd != "^00,$" {
+				t.Errorf("keepalive sent %q, want %q", cmd, "^00,$")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for keepalive poll")
+		}
+	}
+}
+
+func TestClient_Keepalive_LogsFailures(t *testing.T) {
+	appBuf, appLogger := newLockedTestLogger()
+	client := newTestClient(-1)
+	client.appLogger = appLogger
+	client.SetKeepaliveInterval(10 * time.Millisecond)
+	received := newFakePanel(t, client, ackWith("05"))
+
+	<-received
+	<-received // The first failure is logged before the second poll is sent
+
+	want := "WARN: Keepalive poll failed: command 00 rejected: receive state machine timeout"
+	if !strings.Contains(appBuf.String(), want) {
+		t.Errorf("app log should contain %q, got %q", want, appBuf.String())
+	}
+}
+
+func TestClient_Keepalive_Disabled(t *testing.T) {
+	client := newTestClient(-1)
+	client.SetKeepaliveInterval(0)
+	received := newFakePanel(t, client, ackWith("00"))
+
+	select {
+	case cmd := <-received:
+		t.Errorf("unexpected command %q with keepalive disabled", cmd)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
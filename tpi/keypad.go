@@ -0,0 +1,136 @@
+package tpi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// alphaLineWidth is the number of characters on each line of an alpha keypad
+const alphaLineWidth = 16
+
+// BeepMode describes how the virtual keypad should beep
+type BeepMode int
+
+const (
+	BeepOff            BeepMode = 0
+	BeepOnce           BeepMode = 1
+	BeepTwice          BeepMode = 2
+	BeepThrice         BeepMode = 3
+	BeepContinuousFast BeepMode = 4 // Trouble/urgency
+	BeepContinuousSlow BeepMode = 5 // Exit delay
+)
+
+func (b BeepMode) String() string {
+	switch b {
+	case BeepOff:
+		return "off"
+	case BeepOnce, BeepTwice, BeepThrice:
+		return fmt.Sprintf("beep x%d", int(b))
+	case BeepContinuousFast:
+		return "continuous fast"
+	case BeepContinuousSlow:
+		return "continuous slow"
+	}
+	return fmt.Sprintf("unknown (%d)", int(b))
+}
+
+// KeypadLEDs is the decoded LED/ICON bitfield of a keypad update
+type KeypadLEDs struct {
+	Raw                 uint16
+	ArmedStay           bool // Bit 15
+	LowBattery          bool // Bit 14
+	Fire                bool // Bit 13
+	Ready               bool // Bit 12
+	Check               bool // Bit 9: system trouble
+	AlarmFireZone       bool // Bit 8
+	ArmedZeroEntryDelay bool // Bit 7
+	Chime               bool // Bit 5
+	Bypass              bool // Bit 4: zones are bypassed
+	ACPresent           bool // Bit 3
+	ArmedAway           bool // Bit 2
+	AlarmInMemory       bool // Bit 1
+	Alarm               bool // Bit 0: system is in alarm
+}
+
+// DecodeKeypadLEDs splits the raw LED/ICON bitfield into named flags
+func DecodeKeypadLEDs(raw uint16) KeypadLEDs {
+	bit := func(n uint) bool { return raw&(1<<n) != 0 }
+	return KeypadLEDs{
+		Raw:                 raw,
+		ArmedStay:           bit(15),
+		LowBattery:          bit(14),
+		Fire:                bit(13),
+		Ready:               bit(12),
+		Check:               bit(9),
+		AlarmFireZone:       bit(8),
+		ArmedZeroEntryDelay: bit(7),
+		Chime:               bit(5),
+		Bypass:              bit(4),
+		ACPresent:           bit(3),
+		ArmedAway:           bit(2),
+		AlarmInMemory:       bit(1),
+		Alarm:               bit(0),
+	}
+}
+
+// KeypadUpdate is a Virtual Keypad Update (%00)
+type KeypadUpdate struct {
+	Frame
+	Partition  int
+	LEDs       KeypadLEDs
+	UserZone   int // Zone or user number, depending on the panel state
+	Beep       BeepMode
+	Alpha      string // The full 32 character keypad text
+	TopLine    string // First 16 characters of Alpha, trimmed
+	BottomLine string // Last 16 characters of Alpha, trimmed
+}
+
+// parseKeypadUpdate decodes PP,LLLL,UU,BB,ALPHA
+func parseKeypadUpdate(f Frame) (Packet, error) {
+	// The alpha text is last so SplitN keeps any commas it may contain
+	fields := strings.SplitN(f.Data, ",", 5)
+	if len(fields) != 5 {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("keypad update has %d fields, want 5", len(fields))}
+	}
+
+	partition, err := strconv.ParseUint(fields[0], 16, 8)
+	if err != nil || partition < 1 || partition > 8 {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("invalid partition %q", fields[0])}
+	}
+
+	if len(fields[1]) != 4 {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("LED bitfield %q is not 2 bytes", fields[1])}
+	}
+	leds, err := strconv.ParseUint(fields[1], 16, 16)
+	if err != nil {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("invalid LED bitfield %q", fields[1])}
+	}
+
+	userZone, err := strconv.ParseUint(fields[2], 16, 8)
+	if err != nil {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("invalid user/zone field %q", fields[2])}
+	}
+
+	beep, err := strconv.ParseUint(fields[3], 16, 8)
+	if err != nil {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("invalid beep field %q", fields[3])}
+	}
+
+	alpha := fields[4]
+	top, bottom := alpha, ""
+	if len(alpha) > alphaLineWidth {
+		top, bottom = alpha[:alphaLineWidth], alpha[alphaLineWidth:]
+	}
+
+	return KeypadUpdate{
+		Frame:      f,
+		Partition:  int(partition),
+		LEDs:       DecodeKeypadLEDs(uint16(leds)),
+		UserZone:   int(userZone),
+		Beep:       BeepMode(beep),
+		Alpha:      alpha,
+		TopLine:    strings.TrimSpace(top),
+		BottomLine: strings.TrimSpace(bottom),
+	}, nil
+}
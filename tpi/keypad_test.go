@@ -0,0 +1,154 @@
+package tpi
+
+import (
+	"testing"
+)
+
+func TestDecodeKeypadLEDs(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  uint16
+		want KeypadLEDs
+	}{
+		{
+			name: "low battery, ready, AC present (doc example)",
+			raw:  0x5C08,
+			want: KeypadLEDs{Raw: 0x5C08, LowBattery: true, Ready: true, ACPresent: true},
+		},
+		{
+			name: "armed away with chime",
+			raw:  0x0024,
+			want: KeypadLEDs{Raw: 0x0024, Chime: true, ArmedAway: true},
+		},
+		{
+			name: "armed stay, zero entry delay",
+			raw:  0x8080,
+			want: KeypadLEDs{Raw: 0x8080, ArmedStay: true, ArmedZeroEntryDelay: true},
+		},
+		{
+			name: "fire alarm with check and memory",
+			raw:  0x2303,
+			want: KeypadLEDs{Raw: 0x2303, Fire: true, Check: true, AlarmFireZone: true, AlarmInMemory: true, Alarm: true},
+		},
+		{
+			name: "bypass",
+			raw:  0x0010,
+			want: KeypadLEDs{Raw: 0x0010, Bypass: true},
+		},
+		{
+			name: "nothing lit",
+			raw:  0x0000,
+			want: KeypadLEDs{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeKeypadLEDs(tt.raw); got != tt.want {
+				t.Errorf("DecodeKeypadLEDs(%#04x) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_KeypadUpdate(t *testing.T) {
+	tests := []struct {
+		name           string
+		line           string
+		wantPartition  int
+		wantUserZone   int
+		wantBeep       BeepMode
+		wantTopLine    string
+		wantBottomLine string
+		wantErr        bool
+	}{
+		{
+			name:           "disarmed ready to arm",
+			line:           "%00,01,5C08,08,00,****DISARMED**** Ready to Arm$",
+			wantPartition:  1,
+			wantUserZone:   8,
+			wantBeep:       BeepOff,
+			wantTopLine:    "****DISARMED****",
+			wantBottomLine: "Ready to Arm",
+		},
+		{
+			name:           "exit delay on partition 2",
+			line:           "%00,02,8008,00,05,ARMED ***STAY***May Exit Now  15$",
+			wantPartition:  2,
+			wantBeep:       BeepContinuousSlow,
+			wantTopLine:    "ARMED ***STAY***",
+			wantBottomLine: "May Exit Now  15",
+		},
+		{
+			name:           "fault with zone number in hex",
+			line:           "%00,01,0008,1A,00,FAULT 26 BACK DOOR       $",
+			wantPartition:  1,
+			wantUserZone:   26,
+			wantBeep:       BeepOff,
+			wantTopLine:    "FAULT 26 BACK DO",
+			wantBottomLine: "OR",
+		},
+		{
+			name:           "alpha text containing a comma",
+			line:           "%00,01,1C08,00,00,HELLO, WORLD$",
+			wantPartition:  1,
+			wantTopLine:    "HELLO, WORLD",
+			wantBottomLine: "",
+		},
+		{
+			name:    "too few fields",
+			line:    "%00,01,1C08,08$",
+			wantErr: true,
+		},
+		{
+			name:    "partition out of range",
+			line:    "%00,09,1C08,08,00,TEXT$",
+			wantErr: true,
+		},
+		{
+			name:    "short LED bitfield",
+			line:    "%00,01,1C0,08,00,TEXT$",
+			wantErr: true,
+		},
+		{
+			name:    "non-hex beep",
+			line:    "%00,01,1C08,08,XX,TEXT$",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.line)
+			if tt.wantErr {
+				if _, ok := err.(*ParseError); !ok {
+					t.Fatalf("Parse() error = %v, want *ParseError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() returned unexpected error: %v", err)
+			}
+
+			ku, ok := p.(KeypadUpdate)
+			if !ok {
+				t.Fatalf("Parse() type = %T, want KeypadUpdate", p)
+			}
+			if ku.Partition != tt.wantPartition {
+				t.Errorf("Partition = %d, want %d", ku.Partition, tt.wantPartition)
+			}
+			if ku.UserZone != tt.wantUserZone {
+				t.Errorf("UserZone = %d, want %d", ku.UserZone, tt.wantUserZone)
+			}
+			if ku.Beep != tt.wantBeep {
+				t.Errorf("Beep = %v, want %v", ku.Beep, tt.wantBeep)
+			}
+			if ku.TopLine != tt.wantTopLine {
+				t.Errorf("TopLine = %q, want %q", ku.TopLine, tt.wantTopLine)
+			}
+			if ku.BottomLine != tt.wantBottomLine {
+				t.Errorf("BottomLine = %q, want %q", ku.BottomLine, tt.wantBottomLine)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package tpi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TPI command codes sent by the Envisalink (section 3.3)
+const (
+	CodeKeypadUpdate         = "00"
+	CodeZoneStateChange      = "01"
+	CodePartitionStateChange = "02"
+	CodeRealtimeCID          = "03"
+	CodeZoneTimerDump        = "FF"
+)
+
+// Frame is the envelope shared by every TPI packet
+type Frame struct {
+	Code string // Two digit hex command code, upper case (e.g. "00", "FF")
+	Data string // Everything between the comma and the closing '$'
+	Raw  string // The line as received, surrounding whitespace trimmed
+}
+
+// Envelope returns the frame itself, letting any packet that embeds Frame satisfy Packet
+func (f Frame) Envelope() Frame {
+	return f
+}
+
+// Packet is implemented by every typed packet returned from Parse
+type Packet interface {
+	Envelope() Frame
+}
+
+// CommandAck is the Envisalink's reply (^CC,EE$) to an application command
+type CommandAck struct {
+	Frame
+	Result int // TPI response code from section 3.7, 0 means accepted
+}
+
+// Unknown is a well-formed packet whose command code is not recognised
+type Unknown struct {
+	Frame
+}
+
+// Parse decodes a single line received from the TPI into a typed packet.
+// Lines that are not valid %CC,DATA$ or ^CC,EE$ frames return a *ParseError.
+func Parse(line string) (Packet, error) {
+	raw := strings.TrimSpace(line)
+
+	if len(raw) < 5 {
+		return nil, &ParseError{Line: raw, Reason: "frame too short"}
+	}
+
+	start := raw[0]
+	if start != '%' && start != '^' {
+		return nil, &ParseError{Line: raw, Reason: fmt.Sprintf("unexpected start sentinel %q", start)}
+	}
+	if raw[len(raw)-1] != '$' {
+		return nil, &ParseError{Line: raw, Reason: "missing '$' end sentinel"}
+	}
+	if raw[3] != ',' {
+		return nil, &ParseError{Line: raw, Reason: "missing ',' after command code"}
+	}
+
+	code := strings.ToUpper(raw[1:3])
+	if !isHex(code) {
+		return nil, &ParseError{Line: raw, Reason: fmt.Sprintf("command code %q is not hex", code)}
+	}
+
+	f := Frame{
+		Code: code,
+		Data: raw[4 : len(raw)-1],
+		Raw:  raw,
+	}
+
+	if start == '^' {
+		return parseCommandAck(f)
+	}
+
+	switch code {
+	case CodeKeypadUpdate:
+		return parseKeypadUpdate(f)
+	case CodeZoneStateChange:
+		return parseZoneStateChange(f)
+	case CodePartitionStateChange:
+		return parsePartitionStateChange(f)
+	case CodeRealtimeCID:
+		return parseRealtimeCID(f)
+	case CodeZoneTimerDump:
+		return parseZoneTimerDump(f)
+	}
+
+	return Unknown{Frame: f}, nil
+}
+
+// parseCommandAck decodes the two digit response code of a ^CC,EE$ frame
+func parseCommandAck(f Frame) (Packet, error) {
+	if len(f.Data) != 2 || !isHex(f.Data) {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("invalid response code %q", f.Data)}
+	}
+
+	result, _ := strconv.ParseUint(f.Data, 16, 8)
+	return CommandAck{Frame: f, Result: int(result)}, nil
+}
+
+// isHex reports whether s is a non-empty string of hex digits
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package tpi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    Packet
+		wantErr bool
+	}{
+		{
+			name: "keypad update",
+			line: "%00,01,1C08,08,00,****DISARMED****  Ready to Arm  $",
+			want: KeypadUpdate{
+				Frame: Frame{
+					Code: "00",
+					Data: "01,1C08,08,00,****DISARMED****  Ready to Arm  ",
+					Raw:  "%00,01,1C08,08,00,****DISARMED****  Ready to Arm  $",
+				},
+				Partition:  1,
+				LEDs:       DecodeKeypadLEDs(0x1C08),
+				UserZone:   8,
+				Beep:       BeepOff,
+				Alpha:      "****DISARMED****  Ready to Arm  ",
+				TopLine:    "****DISARMED****",
+				BottomLine: "Ready to Arm",
+			},
+		},
+		{
+			name: "zone state change with trailing CR",
+			line: "%01,0100000000000080$\r",
+			want: ZoneStateChange{
+				Frame:     Frame{Code: "01", Data: "0100000000000080", Raw: "%01,0100000000000080$"},
+				ZoneCount: 64,
+				Open:      []int{1, 64},
+			},
+		},
+		{
+			name: "partition state change",
+			line: "%02,0100000000000000$",
+			want: PartitionStateChange{
+				Frame:  Frame{Code: "02", Data: "0100000000000000", Raw: "%02,0100000000000000$"},
+				States: [MaxPartitions]PartitionState{PartitionReady},
+			},
+		},
+		{
+			name: "realtime CID",
+			line: "%03,3441010020$",
+			want: RealtimeCID{
+				Frame:        Frame{Code: "03", Data: "3441010020", Raw: "%03,3441010020$"},
+				Qualifier:    CIDRestore,
+				Code:         441,
+				Category:     CIDOpenClose,
+				Description:  "Armed Stay",
+				ReportString: "Opening-Armed Stay / Closing-Armed Stay",
+				Partition:    1,
+				ZoneOrUser:   2,
+				IsUser:       true,
+			},
+		},
+		{
+			name:    "zone timer dump with wrong width",
+			line:    "%ff,0000$",
+			wantErr: true,
+		},
+		{
+			name: "command ack success",
+			line: "^00,00$",
+			want: CommandAck{Frame: Frame{Code: "00", Data: "00", Raw: "^00,00$"}, Result: 0},
+		},
+		{
+			name: "command ack syntax error",
+			line: "^03,03$",
+			want: CommandAck{Frame: Frame{Code: "03", Data: "03", Raw: "^03,03$"}, Result: 3},
+		},
+		{
+			name: "unknown command code",
+			line: "%A0,DEAD$",
+			want: Unknown{Frame: Frame{Code: "A0", Data: "DEAD", Raw: "%A0,DEAD$"}},
+		},
+		{
+			name: "empty data",
+			line: "%A1,$",
+			want: Unknown{Frame: Frame{Code: "A1", Data: "", Raw: "%A1,$"}},
+		},
+		{
+			name:    "login prompt",
+			line:    "Login:",
+			wantErr: true,
+		},
+		{
+			name:    "empty line",
+			line:    "",
+			wantErr: true,
+		},
+		{
+			name:    "missing end sentinel",
+			line:    "%00,01,1C08",
+			wantErr: true,
+		},
+		{
+			name:    "missing comma",
+			line:    "%0001$",
+			wantErr: true,
+		},
+		{
+			name:    "non-hex command code",
+			line:    "%ZZ,00$",
+			wantErr: true,
+		},
+		{
+			name:    "ack with malformed response code",
+			line:    "^00,E$",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse() expected error, got %+v", got)
+				}
+				if _, ok := err.(*ParseError); !ok {
+					t.Errorf("error type = %T, want *ParseError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() returned unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
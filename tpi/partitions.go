@@ -0,0 +1,131 @@
+package tpi
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// MaxPartitions is the number of partitions reported by a Partition State Change
+const MaxPartitions = 8
+
+// PartitionState is an abstracted partition status code (section 3.4)
+type PartitionState int
+
+const (
+	PartitionNotUsed      PartitionState = 0x00
+	PartitionReady        PartitionState = 0x01
+	PartitionReadyBypass  PartitionState = 0x02 // Ready to arm, zones are bypassed
+	PartitionNotReady     PartitionState = 0x03
+	PartitionArmedStay    PartitionState = 0x04
+	PartitionArmedAway    PartitionState = 0x05
+	PartitionArmedInstant PartitionState = 0x06 // Zero entry delay, stay
+	PartitionExitDelay    PartitionState = 0x07
+	PartitionInAlarm      PartitionState = 0x08
+	PartitionAlarmMemory  PartitionState = 0x09 // Alarm has occurred
+	PartitionArmedMaximum PartitionState = 0x0A // Zero entry delay, away
+)
+
+var partitionStateNames = map[PartitionState]string{
+	PartitionNotUsed:      "Not Used",
+	PartitionReady:        "Ready",
+	PartitionReadyBypass:  "Ready (Zones Bypassed)",
+	PartitionNotReady:     "Not Ready",
+	PartitionArmedStay:    "Armed Stay",
+	PartitionArmedAway:    "Armed Away",
+	PartitionArmedInstant: "Armed Instant",
+	PartitionExitDelay:    "Exit Delay",
+	PartitionInAlarm:      "In Alarm",
+	PartitionAlarmMemory:  "Alarm in Memory",
+	PartitionArmedMaximum: "Armed Maximum",
+}
+
+func (s PartitionState) String() string {
+	if name, ok := partitionStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown (%#02x)", int(s))
+}
+
+// IsArmed reports whether the partition is armed in any mode. An alarm is not
+// an armed state, since 24 hour, fire and panic zones alarm while disarmed.
+func (s PartitionState) IsArmed() bool {
+	switch s {
+	case PartitionArmedStay, PartitionArmedAway, PartitionArmedInstant, PartitionArmedMaximum:
+		return true
+	}
+	return false
+}
+
+// PartitionStateChange is a Partition State Change (%02)
+type PartitionStateChange struct {
+	Frame
+	States [MaxPartitions]PartitionState // States[0] is partition 1
+}
+
+// State returns the state of partition n (1-8), or PartitionNotUsed if out of range
+func (p PartitionStateChange) State(n int) PartitionState {
+	if n < 1 || n > MaxPartitions {
+		return PartitionNotUsed
+	}
+	return p.States[n-1]
+}
+
+// PartitionTransition is a single partition moving from one state to another
+type PartitionTransition struct {
+	Partition int
+	From      PartitionState
+	To        PartitionState
+}
+
+// Action summarises the transition as "alarm", "alarm cleared", "armed",
+// "disarmed" or "changed"
+func (t PartitionTransition) Action() string {
+	switch {
+	case t.To == PartitionInAlarm:
+		return "alarm"
+	case t.From == PartitionInAlarm:
+		return "alarm cleared"
+	case !t.From.IsArmed() && t.To.IsArmed():
+		return "armed"
+	case t.From.IsArmed() && !t.To.IsArmed():
+		return "disarmed"
+	}
+	return "changed"
+}
+
+func (t PartitionTransition) String() string {
+	return fmt.Sprintf("partition %d %s (%s -> %s)", t.Partition, t.Action(), t.From, t.To)
+}
+
+// Diff returns the partitions whose state differs between prev and p
+func (p PartitionStateChange) Diff(prev PartitionStateChange) []PartitionTransition {
+	var transitions []PartitionTransition
+	for i := range p.States {
+		if p.States[i] != prev.States[i] {
+			transitions = append(transitions, PartitionTransition{
+				Partition: i + 1,
+				From:      prev.States[i],
+				To:        p.States[i],
+			})
+		}
+	}
+	return transitions
+}
+
+// parsePartitionStateChange decodes one status byte per partition, partition 1 first
+func parsePartitionStateChange(f Frame) (Packet, error) {
+	if len(f.Data) != MaxPartitions*2 {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("partition states are %d hex chars, want %d", len(f.Data), MaxPartitions*2)}
+	}
+
+	b, err := hex.DecodeString(f.Data)
+	if err != nil {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("invalid partition states: %v", err)}
+	}
+
+	p := PartitionStateChange{Frame: f}
+	for i, octet := range b {
+		p.States[i] = PartitionState(octet)
+	}
+	return p, nil
+}
@@ -0,0 +1,135 @@
+package tpi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse_PartitionStateChange(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantStates [MaxPartitions]PartitionState
+		wantErr    bool
+	}{
+		{
+			name:       "partition 1 ready (doc example)",
+			data:       "0100000000000000",
+			wantStates: [MaxPartitions]PartitionState{PartitionReady},
+		},
+		{
+			name:       "partition 1 and 3 ready (doc example)",
+			data:       "0100010000000000",
+			wantStates: [MaxPartitions]PartitionState{PartitionReady, PartitionNotUsed, PartitionReady},
+		},
+		{
+			name:       "armed away and armed maximum",
+			data:       "050A000000000000",
+			wantStates: [MaxPartitions]PartitionState{PartitionArmedAway, PartitionArmedMaximum},
+		},
+		{
+			name:       "partition 8 in alarm",
+			data:       "0000000000000008",
+			wantStates: [MaxPartitions]PartitionState{7: PartitionInAlarm},
+		},
+		{
+			name:    "too short",
+			data:    "0100",
+			wantErr: true,
+		},
+		{
+			name:    "non-hex",
+			data:    "01000000000000ZZ",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse("%02," + tt.data + "$")
+			if tt.wantErr {
+				if _, ok := err.(*ParseError); !ok {
+					t.Fatalf("Parse() error = %v, want *ParseError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() returned unexpected error: %v", err)
+			}
+
+			ps, ok := p.(PartitionStateChange)
+			if !ok {
+				t.Fatalf("Parse() type = %T, want PartitionStateChange", p)
+			}
+			if ps.States != tt.wantStates {
+				t.Errorf("States = %v, want %v", ps.States, tt.wantStates)
+			}
+		})
+	}
+}
+
+func TestPartitionStateChange_Diff(t *testing.T) {
+	prev := PartitionStateChange{States: [MaxPartitions]PartitionState{PartitionReady, PartitionArmedStay}}
+	cur := PartitionStateChange{States: [MaxPartitions]PartitionState{PartitionArmedAway, PartitionArmedStay, PartitionReady}}
+
+	want := []PartitionTransition{
+		{Partition: 1, From: PartitionReady, To: PartitionArmedAway},
+		{Partition: 3, From: PartitionNotUsed, To: PartitionReady},
+	}
+	if got := cur.Diff(prev); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+}
+
+func TestPartitionTransition_Action(t *testing.T) {
+	tests := []struct {
+		from PartitionState
+		to   PartitionState
+		want string
+	}{
+		{PartitionReady, PartitionArmedAway, "armed"},
+		{PartitionExitDelay, PartitionArmedStay, "armed"},
+		{PartitionArmedStay, PartitionReady, "disarmed"},
+		{PartitionInAlarm, PartitionAlarmMemory, "alarm cleared"},
+		{PartitionInAlarm, PartitionArmedAway, "alarm cleared"},
+		{PartitionArmedAway, PartitionInAlarm, "alarm"},
+		{PartitionReady, PartitionInAlarm, "alarm"},
+		{PartitionAlarmMemory, PartitionReady, "changed"},
+		{PartitionReady, PartitionExitDelay, "changed"},
+		{PartitionReady, PartitionNotReady, "changed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from.String()+" -> "+tt.to.String(), func(t *testing.T) {
+			tr := PartitionTransition{Partition: 1, From: tt.from, To: tt.to}
+			if got := tr.Action(); got != tt.want {
+				t.Errorf("Action() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionState_String(t *testing.T) {
+	if got := PartitionArmedMaximum.String(); got != "Armed Maximum" {
+		t.Errorf("String() = %q, want %q", got, "Armed Maximum")
+	}
+	if got := PartitionState(0x42).String(); got != "Unknown (0x42)" {
+		t.Errorf("String() = %q, want %q", got, "Unknown (0x42)")
+	}
+}
+
+func TestClient_ReadLoop_PartitionTransitions(t *testing.T) {
+	_, tpiLogger := newTestLogger()
+	appBuf, appLogger := newTestLogger()
+
+	client := NewClient("192.168.1.50:4025", "testpass", tpiLogger, appLogger, -1)
+	client.conn = newMockConn("%02,0100000000000000$\n%02,0500000000000000$\n")
+
+	_ = client.ReadLoop()
+
+	want := "partition 1 armed (Ready -> Armed Away)"
+	if appOutput := appBuf.String(); !strings.Contains(appOutput, want) {
+		t.Errorf("app log should contain %q, got %q", want, appOutput)
+	}
+}
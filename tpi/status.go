@@ -0,0 +1,63 @@
+package tpi
+
+import (
+	"sync"
+	"time"
+)
+
+// Status describes the health of the TPI connection
+type Status struct {
+	Address            string
+	Connected          bool      // Authenticated and reading
+	AuthenticatedSince time.Time // Zero while disconnected
+	LastMessage        time.Time // When the last line was received, zero if never
+	Reconnects         int       // Successful connections after the first
+}
+
+// connStatus tracks Status as the connection comes and goes
+type connStatus struct {
+	mu                 sync.Mutex
+	connected          bool
+	authenticatedSince time.Time
+	lastMessage        time.Time
+	connects           int
+}
+
+// Status returns the current connection status. It is safe to call from any goroutine.
+func (c *Client) Status() Status {
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+
+	s := Status{
+		Address:     c.address,
+		Connected:   c.status.connected,
+		LastMessage: c.status.lastMessage,
+	}
+	if s.Connected {
+		s.AuthenticatedSince = c.status.authenticatedSince
+	}
+	if c.status.connects > 1 {
+		s.Reconnects = c.status.connects - 1
+	}
+	return s
+}
+
+func (c *Client) markAuthenticated(at time.Time) {
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+	c.status.connected = true
+	c.status.authenticatedSince = at
+	c.status.connects++
+}
+
+func (c *Client) markDisconnected() {
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+	c.status.connected = false
+}
+
+func (c *Client) markReceived(at time.Time) {
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+	c.status.lastMessage = at
+}
@@ -0,0 +1,46 @@
+package tpi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClient_Status(t *testing.T) {
+	originalDial := dialContext
+	defer func() { dialContext = originalDial }()
+
+	dialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return newMockConn("Login:\r\nOK\r\n"), nil
+	}
+
+	client := newTestClient(-1)
+	if s := client.Status(); s.Connected || !s.LastMessage.IsZero() || s.Reconnects != 0 {
+		t.Errorf("Status() before Connect = %+v, want disconnected", s)
+	}
+
+	before := time.Now()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	s := client.Status()
+	if !s.Connected || s.AuthenticatedSince.Before(before) || s.Reconnects != 0 || s.Address != client.address {
+		t.Errorf("Status() after Connect = %+v, want connected since %v", s, before)
+	}
+
+	client.handleLine("%02,0100000000000000$", time.Now())
+	// The mock connection is already drained, so this sees EOF
+	client.ReadLoop()
+	s = client.Status()
+	if s.Connected || !s.AuthenticatedSince.IsZero() || s.LastMessage.Before(before) {
+		t.Errorf("Status() after disconnect = %+v, want disconnected with last message", s)
+	}
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("second Connect() error = %v", err)
+	}
+	if s := client.Status(); !s.Connected || s.Reconnects != 1 {
+		t.Errorf("Status() after reconnect = %+v, want 1 reconnect", s)
+	}
+}
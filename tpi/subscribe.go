@@ -0,0 +1,93 @@
+package tpi
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSubscriberBuffer is the channel capacity used when Subscribe is given zero
+const DefaultSubscriberBuffer = 100
+
+// Message is a line received from the TPI together with its decoded packet
+type Message struct {
+	Time   time.Time // When the line was received
+	Raw    string    // The line as received
+	Packet Packet    // Decoded packet, nil if Err is set
+	Err    error     // *ParseError if the line was not a valid TPI packet
+}
+
+// subscribers fans received messages out to registered handlers and channels
+type subscribers struct {
+	mu       sync.Mutex
+	handlers []func(Message)
+	subs     map[chan Message]struct{}
+}
+
+// Handle registers fn to be called with every line read by ReadLoop, in order
+// and before subscribers see it. Unlike Subscribe nothing is ever dropped, but
+// fn runs on the read loop so it must return quickly and must not block.
+func (c *Client) Handle(fn func(Message)) {
+	c.subscribers.mu.Lock()
+	defer c.subscribers.mu.Unlock()
+	c.subscribers.handlers = append(c.subscribers.handlers, fn)
+}
+
+// Subscribe registers a channel that receives every line read by ReadLoop,
+// including duplicates hidden from the TPI log. Delivery never blocks the
+// read loop: if the channel is full the message is dropped and a warning is
+// logged. Call the returned function to unsubscribe and close the channel.
+func (c *Client) Subscribe(buffer int) (<-chan Message, func()) {
+	if buffer <= 0 {
+		buffer = DefaultSubscriberBuffer
+	}
+	ch := make(chan Message, buffer)
+
+	c.subscribers.mu.Lock()
+	if c.subscribers.subs == nil {
+		c.subscribers.subs = make(map[chan Message]struct{})
+	}
+	c.subscribers.subs[ch] = struct{}{}
+	c.subscribers.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.subscribers.mu.Lock()
+			defer c.subscribers.mu.Unlock()
+			if _, ok := c.subscribers.subs[ch]; ok {
+				delete(c.subscribers.subs, ch)
+				close(ch)
+			}
+		})
+	}
+}
+
+// publish calls every handler, then delivers msg to every subscriber without
+// blocking
+func (c *Client) publish(msg Message) {
+	c.subscribers.mu.Lock()
+	defer c.subscribers.mu.Unlock()
+
+	for _, fn := range c.subscribers.handlers {
+		fn(msg)
+	}
+
+	for ch := range c.subscribers.subs {
+		select {
+		case ch <- msg:
+		default:
+			c.appLogger.Printf("WARN: Subscriber channel full, dropping message: %s", msg.Raw)
+		}
+	}
+}
+
+// closeSubscribers closes every subscriber channel
+func (c *Client) closeSubscribers() {
+	c.subscribers.mu.Lock()
+	defer c.subscribers.mu.Unlock()
+
+	for ch := range c.subscribers.subs {
+		close(ch)
+	}
+	c.subscribers.subs = nil
+}
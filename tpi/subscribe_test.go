@@ -0,0 +1,105 @@
+package tpi
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_Subscribe(t *testing.T) {
+	client := newTestClient(0)
+	client.conn = newMockConn("%02,0100000000000000$\n%02,0100000000000000$\nLogin:\n")
+
+	ch, unsubscribe := client.Subscribe(0)
+	defer unsubscribe()
+
+	before := time.Now()
+	_ = client.ReadLoop()
+
+	var got []Message
+	for len(ch) > 0 {
+		got = append(got, <-ch)
+	}
+
+	// Duplicates are suppressed in the log but still delivered to subscribers
+	if len(got) != 3 {
+		t.Fatalf("received %d messages, want 3", len(got))
+	}
+	for i, msg := range got[:2] {
+		if _, ok := msg.Packet.(PartitionStateChange); !ok {
+			t.Errorf("message %d packet = %T, want PartitionStateChange", i, msg.Packet)
+		}
+		if msg.Raw != "%02,0100000000000000$" {
+			t.Errorf("message %d raw = %q", i, msg.Raw)
+		}
+		if msg.Time.Before(before) {
+			t.Errorf("message %d time %v is before the read started", i, msg.Time)
+		}
+	}
+	if _, ok := got[2].Err.(*ParseError); !ok || got[2].Packet != nil {
+		t.Errorf("undecodable line = %+v, want nil packet and *ParseError", got[2])
+	}
+}
+
+func TestClient_Subscribe_Unsubscribe(t *testing.T) {
+	client := newTestClient(-1)
+	ch, unsubscribe := client.Subscribe(1)
+
+	unsubscribe()
+	unsubscribe() // Safe to call twice
+
+	if _, open := <-ch; open {
+		t.Error("channel should be closed after unsubscribe")
+	}
+
+	// Publishing with no subscribers must not panic
+	client.publish(Message{Raw: "%00,$"})
+}
+
+func TestClient_Subscribe_SlowSubscriberDropped(t *testing.T) {
+	appBuf, appLogger := newTestLogger()
+	client := newTestClient(-1)
+	client.appLogger = appLogger
+	client.conn = newMockConn("%00,$\n%01,$\n%02,$\n")
+
+	ch, unsubscribe := client.Subscribe(1)
+	defer unsubscribe()
+
+	_ = client.ReadLoop()
+
+	if msg := <-ch; msg.Raw != "%00,$" {
+		t.Errorf("first message = %q, want %q", msg.Raw, "%00,$")
+	}
+	if !strings.Contains(appBuf.String(), "Subscriber channel full") {
+		t.Errorf("app log should report dropped messages, got %q", appBuf.String())
+	}
+}
+
+func TestClient_Handle_NeverDrops(t *testing.T) {
+	client := newTestClient(-1)
+	client.conn = newMockConn("%00,$\n%01,$\n%02,$\n")
+
+	var got []string
+	client.Handle(func(msg Message) { got = append(got, msg.Raw) })
+	_, unsubscribe := client.Subscribe(1)
+	defer unsubscribe()
+
+	_ = client.ReadLoop()
+
+	want := []string{"%00,$", "%01,$", "%02,$"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("handled %v, want %v even with a full subscriber", got, want)
+	}
+}
+
+func TestClient_Close_ClosesSubscribers(t *testing.T) {
+	client := newTestClient(-1)
+	ch, unsubscribe := client.Subscribe(1)
+
+	client.Close()
+	unsubscribe() // Must not double close
+
+	if _, open := <-ch; open {
+		t.Error("channel should be closed after Close")
+	}
+}
@@ -1,10 +1,13 @@
 package tpi
 
 import (
+	"bufio"
 	"bytes"
 	"io"
 	"log"
 	"net"
+	"sync"
+	"testing"
 	"time"
 )
 
@@ -64,6 +67,30 @@ func newTestLogger() (*bytes.Buffer, *log.Logger) {
 	return buf, logger
 }
 
+// lockedBuffer is a bytes.Buffer safe for loggers written from several goroutines
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// newLockedTestLogger creates a logger for tests where background goroutines log
+func newLockedTestLogger() (*lockedBuffer, *log.Logger) {
+	buf := &lockedBuffer{}
+	return buf, log.New(buf, "", 0)
+}
+
 // newTestClient creates a client with test defaults
 func newTestClient(deduplicateLimit int) *Client {
 	return NewClient(
@@ -74,3 +101,50 @@ func newTestClient(deduplicateLimit int) *Client {
 		deduplicateLimit,
 	)
 }
+
+// ackWith returns a fake panel responder that acks every command with the given code
+func ackWith(result string) func(cmd string) string {
+	return func(cmd string) string {
+		return "^" + cmd[1:3] + "," + result + "$"
+	}
+}
+
+// newFakePanel attaches client to an in-memory Envisalink over net.Pipe and runs
+// ReadLoop. Each command the client sends is published on the returned channel
+// and answered with respond(cmd), or not at all if respond returns "".
+func newFakePanel(t *testing.T, client *Client, respond func(cmd string) string) <-chan string {
+	t.Helper()
+
+	server, conn := net.Pipe()
+	client.conn = conn
+
+	received := make(chan string, 100)
+	go func() {
+		reader := bufio.NewReader(server)
+		for {
+			cmd, err := reader.ReadString('$')
+			if err != nil {
+				return
+			}
+			received <- cmd
+			if reply := respond(cmd); reply != "" {
+				if _, err := io.WriteString(server, reply+"\r\n"); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		client.ReadLoop()
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		server.Close()
+		conn.Close()
+		<-done
+	})
+	return received
+}
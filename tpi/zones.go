@@ -0,0 +1,83 @@
+package tpi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Zone capacities of the two Envisalink generations
+const (
+	ZonesEVL3 = 64
+	ZonesEVL4 = 128
+)
+
+// ZoneStateChange is a Zone State Change (%01)
+type ZoneStateChange struct {
+	Frame
+	ZoneCount int   // 64 on an Envisalink 3, 128 on an Envisalink 4
+	Open      []int // Open/faulted zone numbers in ascending order
+}
+
+// IsOpen reports whether the given zone is open/faulted
+func (z ZoneStateChange) IsOpen(zone int) bool {
+	i := sort.SearchInts(z.Open, zone)
+	return i < len(z.Open) && z.Open[i] == zone
+}
+
+// ZoneTransition is a single zone changing between faulted and restored
+type ZoneTransition struct {
+	Zone    int
+	Faulted bool // true when the zone opened, false when it was restored
+}
+
+func (t ZoneTransition) String() string {
+	if t.Faulted {
+		return fmt.Sprintf("zone %d faulted", t.Zone)
+	}
+	return fmt.Sprintf("zone %d restored", t.Zone)
+}
+
+// Diff returns the per-zone transitions from prev to z, ordered by zone number
+func (z ZoneStateChange) Diff(prev ZoneStateChange) []ZoneTransition {
+	var transitions []ZoneTransition
+	i, j := 0, 0
+	for i < len(prev.Open) || j < len(z.Open) {
+		switch {
+		case j == len(z.Open) || (i < len(prev.Open) && prev.Open[i] < z.Open[j]):
+			transitions = append(transitions, ZoneTransition{Zone: prev.Open[i], Faulted: false})
+			i++
+		case i == len(prev.Open) || z.Open[j] < prev.Open[i]:
+			transitions = append(transitions, ZoneTransition{Zone: z.Open[j], Faulted: true})
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return transitions
+}
+
+// parseZoneStateChange decodes the little-endian zone bitfield. Byte n covers
+// zones 8n+1..8n+8 with the least significant bit being the lowest zone.
+func parseZoneStateChange(f Frame) (Packet, error) {
+	if len(f.Data) != ZonesEVL3/4 && len(f.Data) != ZonesEVL4/4 {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("zone bitfield is %d hex chars, want %d or %d", len(f.Data), ZonesEVL3/4, ZonesEVL4/4)}
+	}
+
+	b, err := hex.DecodeString(f.Data)
+	if err != nil {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("invalid zone bitfield: %v", err)}
+	}
+
+	open := []int{}
+	for n, octet := range b {
+		for bit := 0; bit < 8; bit++ {
+			if octet&(1<<bit) != 0 {
+				open = append(open, n*8+bit+1)
+			}
+		}
+	}
+
+	return ZoneStateChange{Frame: f, ZoneCount: len(b) * 8, Open: open}, nil
+}
@@ -0,0 +1,159 @@
+package tpi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse_ZoneStateChange(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		wantZoneCount int
+		wantOpen      []int
+		wantErr       bool
+	}{
+		{
+			name:          "no zones open",
+			data:          "0000000000000000",
+			wantZoneCount: 64,
+			wantOpen:      []int{},
+		},
+		{
+			name:          "zone 1 and 64 open (doc example)",
+			data:          "0100000000000080",
+			wantZoneCount: 64,
+			wantOpen:      []int{1, 64},
+		},
+		{
+			name:          "zones 2, 3 and 9",
+			data:          "0601000000000000",
+			wantZoneCount: 64,
+			wantOpen:      []int{2, 3, 9},
+		},
+		{
+			name:          "EVL4 zone 128 open",
+			data:          "000000000000000000000000000000" + "80",
+			wantZoneCount: 128,
+			wantOpen:      []int{128},
+		},
+		{
+			name:          "EVL4 zones 65 and 66",
+			data:          "0000000000000000" + "0300000000000000",
+			wantZoneCount: 128,
+			wantOpen:      []int{65, 66},
+		},
+		{
+			name:    "wrong width",
+			data:    "00000000",
+			wantErr: true,
+		},
+		{
+			name:    "non-hex payload",
+			data:    "0G00000000000000",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse("%01," + tt.data + "$")
+			if tt.wantErr {
+				if _, ok := err.(*ParseError); !ok {
+					t.Fatalf("Parse() error = %v, want *ParseError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() returned unexpected error: %v", err)
+			}
+
+			z, ok := p.(ZoneStateChange)
+			if !ok {
+				t.Fatalf("Parse() type = %T, want ZoneStateChange", p)
+			}
+			if z.ZoneCount != tt.wantZoneCount {
+				t.Errorf("ZoneCount = %d, want %d", z.ZoneCount, tt.wantZoneCount)
+			}
+			if !reflect.DeepEqual(z.Open, tt.wantOpen) {
+				t.Errorf("Open = %v, want %v", z.Open, tt.wantOpen)
+			}
+			for _, zone := range tt.wantOpen {
+				if !z.IsOpen(zone) {
+					t.Errorf("IsOpen(%d) = false, want true", zone)
+				}
+			}
+		})
+	}
+}
+
+func TestZoneStateChange_Diff(t *testing.T) {
+	tests := []struct {
+		name string
+		prev []int
+		cur  []int
+		want []ZoneTransition
+	}{
+		{
+			name: "no change",
+			prev: []int{1, 5},
+			cur:  []int{1, 5},
+			want: nil,
+		},
+		{
+			name: "zone faulted",
+			prev: []int{},
+			cur:  []int{3},
+			want: []ZoneTransition{{Zone: 3, Faulted: true}},
+		},
+		{
+			name: "zone restored",
+			prev: []int{3},
+			cur:  []int{},
+			want: []ZoneTransition{{Zone: 3, Faulted: false}},
+		},
+		{
+			name: "mixed changes ordered by zone",
+			prev: []int{1, 4, 9},
+			cur:  []int{2, 4, 10, 100},
+			want: []ZoneTransition{
+				{Zone: 1, Faulted: false},
+				{Zone: 2, Faulted: true},
+				{Zone: 9, Faulted: false},
+				{Zone: 10, Faulted: true},
+				{Zone: 100, Faulted: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := ZoneStateChange{Open: tt.cur}
+			got := cur.Diff(ZoneStateChange{Open: tt.prev})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_ReadLoop_ZoneTransitions(t *testing.T) {
+	_, tpiLogger := newTestLogger()
+	appBuf, appLogger := newTestLogger()
+
+	client := NewClient("192.168.1.50:4025", "testpass", tpiLogger, appLogger, -1)
+	client.conn = newMockConn("%01,0100000000000000$\n%01,0200000000000000$\n")
+
+	_ = client.ReadLoop()
+
+	appOutput := appBuf.String()
+	for _, want := range []string{"zone 1 restored", "zone 2 faulted"} {
+		if !strings.Contains(appOutput, want) {
+			t.Errorf("app log should contain %q, got %q", want, appOutput)
+		}
+	}
+	if strings.Contains(appOutput, "zone 1 faulted") {
+		t.Errorf("first zone update should only set the baseline, got %q", appOutput)
+	}
+}
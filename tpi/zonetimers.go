@@ -0,0 +1,87 @@
+package tpi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// ZoneTimerTick is the time represented by one count of a zone timer
+const ZoneTimerTick = 5 * time.Second
+
+const (
+	zoneTimerOpen    = 0xFFFF // Zone is currently open
+	zoneTimerUnknown = 0x0000 // Zone closed too long ago to remember
+)
+
+// ZoneTimer is the decoded timer of a single zone
+type ZoneTimer struct {
+	Zone int
+	Raw  uint16
+}
+
+// IsOpen reports whether the zone is currently open
+func (t ZoneTimer) IsOpen() bool {
+	return t.Raw == zoneTimerOpen
+}
+
+// IsUnknown reports whether the zone closed too long ago for the Envisalink to remember
+func (t ZoneTimer) IsUnknown() bool {
+	return t.Raw == zoneTimerUnknown
+}
+
+// LastFaulted returns how long ago the zone was last faulted. ok is false when
+// the zone is currently open or the time is unknown.
+func (t ZoneTimer) LastFaulted() (ago time.Duration, ok bool) {
+	if t.IsOpen() || t.IsUnknown() {
+		return 0, false
+	}
+	return time.Duration(zoneTimerOpen-t.Raw) * ZoneTimerTick, true
+}
+
+func (t ZoneTimer) String() string {
+	switch {
+	case t.IsOpen():
+		return fmt.Sprintf("zone %d open", t.Zone)
+	case t.IsUnknown():
+		return fmt.Sprintf("zone %d last faulted unknown", t.Zone)
+	}
+	ago, _ := t.LastFaulted()
+	return fmt.Sprintf("zone %d last faulted %v ago", t.Zone, ago)
+}
+
+// ZoneTimerDump is an Envisalink Zone Timer Dump (%FF)
+type ZoneTimerDump struct {
+	Frame
+	Timers []ZoneTimer // One entry per zone, Timers[0] is zone 1
+}
+
+// Timer returns the timer for the given zone, or false if the zone is out of range
+func (d ZoneTimerDump) Timer(zone int) (ZoneTimer, bool) {
+	if zone < 1 || zone > len(d.Timers) {
+		return ZoneTimer{}, false
+	}
+	return d.Timers[zone-1], true
+}
+
+// parseZoneTimerDump decodes 64 (EVL3) or 128 (EVL4) little-endian UINT16 timers
+func parseZoneTimerDump(f Frame) (Packet, error) {
+	if len(f.Data) != ZonesEVL3*4 && len(f.Data) != ZonesEVL4*4 {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("zone timer dump is %d hex chars, want %d or %d", len(f.Data), ZonesEVL3*4, ZonesEVL4*4)}
+	}
+
+	b, err := hex.DecodeString(f.Data)
+	if err != nil {
+		return nil, &ParseError{Line: f.Raw, Reason: fmt.Sprintf("invalid zone timers: %v", err)}
+	}
+
+	timers := make([]ZoneTimer, len(b)/2)
+	for i := range timers {
+		timers[i] = ZoneTimer{
+			Zone: i + 1,
+			Raw:  uint16(b[2*i]) | uint16(b[2*i+1])<<8,
+		}
+	}
+
+	return ZoneTimerDump{Frame: f, Timers: timers}, nil
+}
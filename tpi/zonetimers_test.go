@@ -0,0 +1,100 @@
+package tpi
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse_ZoneTimerDump(t *testing.T) {
+	// Zone 1 open, zone 2 five seconds ago, zone 3 three minutes ago, rest unknown
+	evl3 := "FFFF" + "FEFF" + "DBFF" + strings.Repeat("0000", 61)
+
+	p, err := Parse("%FF," + evl3 + "$")
+	if err != nil {
+		t.Fatalf("Parse() returned unexpected error: %v", err)
+	}
+	d, ok := p.(ZoneTimerDump)
+	if !ok {
+		t.Fatalf("Parse() type = %T, want ZoneTimerDump", p)
+	}
+	if len(d.Timers) != ZonesEVL3 {
+		t.Fatalf("len(Timers) = %d, want %d", len(d.Timers), ZonesEVL3)
+	}
+
+	tests := []struct {
+		zone        int
+		wantOpen    bool
+		wantUnknown bool
+		wantAgo     time.Duration
+		wantOK      bool
+	}{
+		{zone: 1, wantOpen: true},
+		{zone: 2, wantAgo: 5 * time.Second, wantOK: true},
+		{zone: 3, wantAgo: 3 * time.Minute, wantOK: true},
+		{zone: 64, wantUnknown: true},
+	}
+
+	for _, tt := range tests {
+		timer, found := d.Timer(tt.zone)
+		if !found {
+			t.Fatalf("Timer(%d) not found", tt.zone)
+		}
+		if timer.Zone != tt.zone {
+			t.Errorf("Timer(%d).Zone = %d", tt.zone, timer.Zone)
+		}
+		if timer.IsOpen() != tt.wantOpen {
+			t.Errorf("zone %d IsOpen() = %v, want %v", tt.zone, timer.IsOpen(), tt.wantOpen)
+		}
+		if timer.IsUnknown() != tt.wantUnknown {
+			t.Errorf("zone %d IsUnknown() = %v, want %v", tt.zone, timer.IsUnknown(), tt.wantUnknown)
+		}
+		ago, ok := timer.LastFaulted()
+		if ago != tt.wantAgo || ok != tt.wantOK {
+			t.Errorf("zone %d LastFaulted() = %v, %v, want %v, %v", tt.zone, ago, ok, tt.wantAgo, tt.wantOK)
+		}
+	}
+
+	if _, found := d.Timer(65); found {
+		t.Error("Timer(65) found on an EVL3 dump")
+	}
+	if got := d.Timers[2].String(); got != "zone 3 last faulted 3m0s ago" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParse_ZoneTimerDump_EVL4(t *testing.T) {
+	evl4 := strings.Repeat("0000", 127) + "FFFF"
+
+	p, err := Parse("%FF," + evl4 + "$")
+	if err != nil {
+		t.Fatalf("Parse() returned unexpected error: %v", err)
+	}
+	d := p.(ZoneTimerDump)
+	if len(d.Timers) != ZonesEVL4 {
+		t.Fatalf("len(Timers) = %d, want %d", len(d.Timers), ZonesEVL4)
+	}
+	if timer, _ := d.Timer(128); !timer.IsOpen() {
+		t.Errorf("zone 128 should be open, got %v", timer)
+	}
+}
+
+func TestParse_ZoneTimerDump_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "wrong width", data: strings.Repeat("0000", 10)},
+		{name: "non-hex", data: "ZZZZ" + strings.Repeat("0000", 63)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse("%FF," + tt.data + "$"); err == nil {
+				t.Error("Parse() expected error, got nil")
+			} else if _, ok := err.(*ParseError); !ok {
+				t.Errorf("error type = %T, want *ParseError", err)
+			}
+		})
+	}
+}